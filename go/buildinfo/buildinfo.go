@@ -0,0 +1,77 @@
+// Package buildinfo describes how the server was built so users can check that the files it serves were built from the published source.
+package buildinfo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"runtime/debug"
+)
+
+// Path is the path of the description of the build of the server.
+const Path = "/build.json"
+
+// Info describes how the server was built.
+type Info struct {
+	// Version is the version of the server, from build/version.
+	Version string `json:"version"`
+	// GoVersion is the Go toolchain that built the server.
+	// The WebAssembly is built with the same toolchain.
+	GoVersion string `json:"goVersion"`
+	// Settings are the build flags, target, and version control information of the server binary.
+	Settings map[string]string `json:"settings,omitempty"`
+	// Assets are the hex SHA-256 hashes of the embedded files, by path, in the format of sha256sum.
+	Assets map[string]string `json:"assets"`
+}
+
+// New describes the build of the running program and the files of the filesystems, by their root directories.
+func New(version string, fsyss map[string]fs.FS) (*Info, error) {
+	b := Info{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Assets:    make(map[string]string),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		b.GoVersion = info.GoVersion
+		b.Settings = make(map[string]string, len(info.Settings))
+		for _, s := range info.Settings {
+			b.Settings[s.Key] = s.Value
+		}
+	}
+	for root, fsys := range fsyss {
+		if fsys == nil {
+			continue
+		}
+		hashes, err := HashAssets(fsys, root)
+		if err != nil {
+			return nil, err
+		}
+		for name, hash := range hashes {
+			b.Assets[name] = hash
+		}
+	}
+	return &b, nil
+}
+
+// HashAssets computes the hex SHA-256 hashes of the files in the root directory of the filesystem, by path.
+func HashAssets(fsys fs.FS, root string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	err := fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		hashes[path] = hex.EncodeToString(sum[:])
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hashing files in %v: %w", root, err)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,58 @@
+package buildinfo
+
+import (
+	"io/fs"
+	"runtime"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHashAssets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"build/main.wasm":    {Data: []byte("abc")},
+		"build/sub/empty.js": {},
+		"other/ignored.txt":  {Data: []byte("ignored")},
+	}
+	want := map[string]string{
+		"build/main.wasm":    "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"build/sub/empty.js": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}
+	got, err := HashAssets(fsys, "build")
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if len(want) != len(got) {
+		t.Errorf("wanted %v hashes, got %v", len(want), got)
+	}
+	for name, hash := range want {
+		if got[name] != hash {
+			t.Errorf("wanted hash of %v to be %v, got %v", name, hash, got[name])
+		}
+	}
+	if _, err := HashAssets(fsys, "missing"); err == nil {
+		t.Errorf("wanted error hashing missing directory")
+	}
+}
+
+func TestNew(t *testing.T) {
+	fsys := fstest.MapFS{
+		"build/main.wasm":       {Data: []byte("abc")},
+		"resources/robots.txt":  {},
+		"resources/other/a.txt": {},
+	}
+	got, err := New("v1", map[string]fs.FS{
+		"build":     fsys,
+		"resources": fsys,
+		"missing":   nil,
+	})
+	switch {
+	case err != nil:
+		t.Fatalf("unwanted error: %v", err)
+	case got.Version != "v1":
+		t.Errorf("wanted version v1, got %q", got.Version)
+	case got.GoVersion != runtime.Version():
+		t.Errorf("wanted go version %v, got %v", runtime.Version(), got.GoVersion)
+	case len(got.Assets) != 3:
+		t.Errorf("wanted hashes of files in both roots, got %v", got.Assets)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/buildinfo"
+)
+
+// buildVerify compares the hashes of the files a server was built with to the files of a local build.
+// The local build should be made with the same toolchain from the source of the version of the server.
+func (c command) buildVerify(args []string) error {
+	fs := flag.NewFlagSet("build verify", flag.ContinueOnError)
+	dir := fs.String("dir", "go/cmd/server", "The directory with the resources and build directories of the local build.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: otp build verify [-dir directory] <server url>")
+	}
+	remote, err := fetchBuildInfo(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	local := make(map[string]string)
+	for _, root := range []string{"resources", "build"} {
+		hashes, err := buildinfo.HashAssets(os.DirFS(*dir), root)
+		if err != nil {
+			return err
+		}
+		for name, hash := range hashes {
+			local[name] = hash
+		}
+	}
+	fmt.Fprintf(c.stdout, "version: %v\n", remote.Version)
+	fmt.Fprintf(c.stdout, "go: %v (local %v)\n", remote.GoVersion, runtime.Version())
+	names := make([]string, 0, len(local))
+	for name := range local {
+		names = append(names, name)
+	}
+	for name := range remote.Assets {
+		if _, ok := local[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tFILE")
+	var differences int
+	for _, name := range names {
+		want, served := remote.Assets[name]
+		got, built := local[name]
+		status := "ok"
+		switch {
+		case !served:
+			status = "not served"
+		case !built:
+			status = "not built"
+		case want != got:
+			status = "different"
+		}
+		if status != "ok" {
+			differences++
+		}
+		fmt.Fprintf(w, "%v\t%v\n", status, name)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if differences != 0 {
+		return fmt.Errorf("%v of %v files do not match the local build", differences, len(names))
+	}
+	return nil
+}
+
+// fetchBuildInfo gets the description of the build of the server at the url.
+func fetchBuildInfo(serverURL string) (*buildinfo.Info, error) {
+	resp, err := http.Get(strings.TrimSuffix(serverURL, "/") + buildinfo.Path)
+	if err != nil {
+		return nil, fmt.Errorf("fetching build info: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching build info: %v", resp.Status)
+	}
+	var b buildinfo.Info
+	if err := json.NewDecoder(resp.Body).Decode(&b); err != nil {
+		return nil, fmt.Errorf("decoding build info: %w", err)
+	}
+	return &b, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/buildinfo"
+)
+
+func TestBuildVerify(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"resources/html/main.html": "<html></html>",
+		"build/main.wasm":          "wasm",
+		"build/version":            "v1",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("creating directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("writing file: %v", err)
+		}
+	}
+	b := buildinfo.Info{
+		Version: "v1",
+		Assets:  make(map[string]string),
+	}
+	for _, root := range []string{"resources", "build"} {
+		hashes, err := buildinfo.HashAssets(os.DirFS(dir), root)
+		if err != nil {
+			t.Fatalf("hashing files: %v", err)
+		}
+		for name, hash := range hashes {
+			b.Assets[name] = hash
+		}
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/build.json" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(b)
+	}))
+	defer ts.Close()
+	var stdout bytes.Buffer
+	c := command{
+		stdout: &stdout,
+	}
+	if err := c.run([]string{"build", "verify", "-dir", dir, ts.URL}); err != nil {
+		t.Fatalf("unwanted error: %v\n%v", err, stdout.String())
+	}
+	if got := stdout.String(); !strings.Contains(got, "ok      build/main.wasm") {
+		t.Errorf("wanted matching wasm, got:\n%v", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "build/main.wasm"), []byte("tampered"), 0600); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	stdout.Reset()
+	err := c.run([]string{"build", "verify", "-dir", dir, ts.URL})
+	switch {
+	case err == nil:
+		t.Errorf("wanted error when files do not match")
+	case !strings.Contains(stdout.String(), "different  build/main.wasm"):
+		t.Errorf("wanted different wasm, got:\n%v", stdout.String())
+	}
+	if err := c.run([]string{"build", "verify", "-dir", dir, ts.URL + "/missing"}); err == nil {
+		t.Errorf("wanted error fetching build info from wrong url")
+	}
+}
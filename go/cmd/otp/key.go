@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+// newKeyFlagSet creates a flag set for the key command that populates the passphrase file.
+func newKeyFlagSet(command string, passphraseFile *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("key "+command, flag.ContinueOnError)
+	fs.StringVar(passphraseFile, "passphrase-file", "", "The file with the passphrase of protected keys.")
+	return fs
+}
+
+// keyProtect writes the keys in the file, or stdin, protected with the passphrase.
+func (c command) keyProtect(args []string) error {
+	return c.transformKeys("protect", args, otp.Protect)
+}
+
+// keyUnprotect writes the keys in the file, or stdin, unprotected with the passphrase.
+func (c command) keyUnprotect(args []string) error {
+	return c.transformKeys("unprotect", args, otp.Unprotect)
+}
+
+// transformKeys writes the keys of the file in the arguments after changing them with the passphrase.
+func (c command) transformKeys(command string, args []string, transform func(text []byte, passphrase string) ([]byte, error)) error {
+	var passphraseFile string
+	fs := newKeyFlagSet(command, &passphraseFile)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	name := "-"
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		name = fs.Arg(0)
+	default:
+		return fmt.Errorf("at most one key file can be named")
+	}
+	b, err := c.readInput(name)
+	if err != nil {
+		return err
+	}
+	passphrase, err := c.passphrase(passphraseFile)
+	if err != nil {
+		return err
+	}
+	keys, err := transform(b, passphrase)
+	if err != nil {
+		return fmt.Errorf("%v %v: %w", command, name, err)
+	}
+	_, err = c.stdout.Write(keys)
+	return err
+}
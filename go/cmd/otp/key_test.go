@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyProtectUnprotect(t *testing.T) {
+	key := `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`
+	env := func(name string) (string, bool) {
+		if name == environmentVariablePassphrase {
+			return "secret", true
+		}
+		return "", false
+	}
+	var protected bytes.Buffer
+	c := command{
+		stdin:     strings.NewReader(key),
+		stdout:    &protected,
+		lookupEnv: env,
+	}
+	if err := c.run([]string{"key", "protect"}); err != nil {
+		t.Fatalf("protecting key: %v", err)
+	}
+	if strings.Contains(protected.String(), "AQIDBAU=") {
+		t.Errorf("wanted key to be protected, got %v", protected.String())
+	}
+	dir := t.TempDir()
+	passphrasePath := filepath.Join(dir, "passphrase.txt")
+	if err := os.WriteFile(passphrasePath, []byte("secret\n"), 0600); err != nil {
+		t.Fatalf("writing passphrase: %v", err)
+	}
+	keyringPath := filepath.Join(dir, "keyring.pem")
+	c = command{
+		stdin:     strings.NewReader(protected.String()),
+		stdout:    new(bytes.Buffer),
+		now:       time.Now,
+		lookupEnv: func(string) (string, bool) { return "", false },
+	}
+	if err := c.run([]string{"keyring", "import", "-keyring", keyringPath}); err == nil {
+		t.Errorf("wanted error importing protected key without passphrase")
+	}
+	c.stdin = strings.NewReader(protected.String())
+	if err := c.run([]string{"keyring", "import", "-keyring", keyringPath, "-passphrase-file", passphrasePath}); err != nil {
+		t.Errorf("importing protected key: %v", err)
+	}
+	if b, err := os.ReadFile(keyringPath); err != nil || strings.Contains(string(b), "AQIDBAU=") {
+		t.Errorf("wanted keyring with imported protected key to be protected, got %v, %v", string(b), err)
+	}
+	if err := c.run([]string{"keyring", "list", "-keyring", keyringPath}); err == nil {
+		t.Errorf("wanted error listing protected keyring without passphrase")
+	}
+	if err := c.run([]string{"keyring", "list", "-keyring", keyringPath, "-passphrase-file", passphrasePath}); err != nil {
+		t.Errorf("listing protected keyring: %v", err)
+	}
+	var unprotected bytes.Buffer
+	c = command{
+		stdin:  strings.NewReader(protected.String()),
+		stdout: &unprotected,
+	}
+	switch err := c.run([]string{"key", "unprotect", "-passphrase-file", passphrasePath}); {
+	case err != nil:
+		t.Errorf("unprotecting key: %v", err)
+	case key != unprotected.String():
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", key, unprotected.String())
+	}
+}
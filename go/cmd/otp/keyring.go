@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+// keyringFlags are the flags to choose the keyring file.
+type keyringFlags struct {
+	path           string
+	passphraseFile string
+}
+
+// newKeyringFlagSet creates a flag set for the keyring command that populates the flags.
+func newKeyringFlagSet(command string, f *keyringFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("keyring "+command, flag.ContinueOnError)
+	fs.StringVar(&f.path, "keyring", "keyring.pem", "The keyring file.")
+	fs.StringVar(&f.passphraseFile, "passphrase-file", "", "The file with the passphrase of protected keys.")
+	return fs
+}
+
+// readKeyring reads the keyring file, creating an empty keyring if the file does not exist and create is true.
+// A keyring that is protected with a passphrase, such as by "otp key protect", is unprotected and protected is true.
+func (c command) readKeyring(f keyringFlags, create bool) (k *otp.Keyring, protected bool, err error) {
+	b, err := os.ReadFile(f.path)
+	switch {
+	case create && errors.Is(err, fs.ErrNotExist):
+		return new(otp.Keyring), false, nil
+	case err != nil:
+		return nil, false, fmt.Errorf("reading keyring: %w", err)
+	}
+	if otp.IsProtected(b) {
+		passphrase, err := c.passphrase(f.passphraseFile)
+		if err != nil {
+			return nil, false, err
+		}
+		b, err = otp.Unprotect(b, passphrase)
+		if err != nil {
+			return nil, false, fmt.Errorf("unprotecting keyring: %w", err)
+		}
+		protected = true
+	}
+	defer clear(b)
+	k, err = otp.ParseKeyring(b)
+	if err != nil {
+		return nil, false, fmt.Errorf("parsing keyring: %w", err)
+	}
+	return k, protected, nil
+}
+
+// writeKeyring replaces the keyring file with the keyring, protected with the passphrase if protected is true.
+// The file is only readable by the user because it holds keys.
+func (c command) writeKeyring(f keyringFlags, k otp.Keyring, protected bool) error {
+	b, err := k.MarshalText()
+	if err != nil {
+		return fmt.Errorf("encoding keyring: %w", err)
+	}
+	defer clear(b)
+	if protected {
+		passphrase, err := c.passphrase(f.passphraseFile)
+		if err != nil {
+			return err
+		}
+		b, err = otp.Protect(b, passphrase)
+		if err != nil {
+			return fmt.Errorf("protecting keyring: %w", err)
+		}
+	}
+	if err := os.WriteFile(f.path, b, 0600); err != nil {
+		return fmt.Errorf("writing keyring: %w", err)
+	}
+	return nil
+}
+
+// keyringList writes the ids, labels, partners, and usage of keys in the keyring.
+func (c command) keyringList(args []string) error {
+	var f keyringFlags
+	fs := newKeyringFlagSet("list", &f)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	k, _, err := c.readKeyring(f, false)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tLABEL\tPARTNER\tCREATED\tNEXT OFFSET")
+	for _, e := range k.Entries {
+		created := ""
+		if !e.Created.IsZero() {
+			created = e.Created.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", e.ID, e.Label, e.Partner, created, e.Next())
+	}
+	return w.Flush()
+}
+
+// keyringImport adds the keys in the files to the keyring.
+// Keys are read from stdin if no files are named.
+// Protected keys are unprotected with the passphrase, and then the keyring is protected with it too so the keys are never stored unprotected.
+// Used ranges of keys from other keyrings are kept.
+// If the strict flag is set, each file must be a single key without any text around it, such as a key file that was not changed.
+func (c command) keyringImport(args []string) error {
+	var f keyringFlags
+	fs := newKeyringFlagSet("import", &f)
+	strict := fs.Bool("strict", false, "Only import files that are a single key without any text around it.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	k, protected, err := c.readKeyring(f, true)
+	if err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		b, err := c.readInput(name)
+		if err != nil {
+			return err
+		}
+		if *strict {
+			if err := otp.ValidateStrict(b); err != nil {
+				return fmt.Errorf("reading %v: %w", name, err)
+			}
+		}
+		if otp.IsProtected(b) {
+			passphrase, err := c.passphrase(f.passphraseFile)
+			if err != nil {
+				return err
+			}
+			b, err = otp.Unprotect(b, passphrase)
+			if err != nil {
+				return fmt.Errorf("unprotecting %v: %w", name, err)
+			}
+			protected = true
+		}
+		src, err := otp.ParseKeyring(b)
+		if err != nil {
+			return fmt.Errorf("parsing %v: %w", name, err)
+		}
+		for _, e := range src.Entries {
+			if _, err := k.Select(e.ID); err == nil {
+				return fmt.Errorf("importing %v: %w: %v", name, otp.ErrDuplicateKey, e.ID)
+			}
+			if e.Created.IsZero() {
+				e.Created = c.now()
+			}
+			k.Entries = append(k.Entries, e)
+		}
+	}
+	return c.writeKeyring(f, *k, protected)
+}
+
+// keyringExport writes the key with the id from the keyring as a key file.
+// The key is protected with the passphrase if the protect flag is set.
+func (c command) keyringExport(args []string) error {
+	var f keyringFlags
+	fs := newKeyringFlagSet("export", &f)
+	id := fs.String("id", "", "The id of the key to export.")
+	protect := fs.Bool("protect", false, "Protect the key file with the passphrase.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	k, _, err := c.readKeyring(f, false)
+	if err != nil {
+		return err
+	}
+	b, err := k.Export(*id)
+	if err != nil {
+		return err
+	}
+	if *protect {
+		passphrase, err := c.passphrase(f.passphraseFile)
+		if err != nil {
+			return err
+		}
+		b, err = otp.Protect(b, passphrase)
+		if err != nil {
+			return fmt.Errorf("protecting key: %w", err)
+		}
+	}
+	_, err = c.stdout.Write(b)
+	return err
+}
+
+// keyringConsume records that part of the key with the id was used, such as to encrypt a message with a copy of the key.
+// The part starts at the offset, or after all used parts of the key if the offset is negative.
+// The offset of the part is written so it can be used to encrypt the message.
+func (c command) keyringConsume(args []string) error {
+	var f keyringFlags
+	fs := newKeyringFlagSet("consume", &f)
+	id := fs.String("id", "", "The id of the key to use.")
+	offset := fs.Int("offset", -1, "The offset of the used part of the key, or -1 to use the part after all used parts.")
+	length := fs.Int("length", 0, "The number of bytes of the key that were used.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	k, protected, err := c.readKeyring(f, false)
+	if err != nil {
+		return err
+	}
+	e, err := k.Select(*id)
+	if err != nil {
+		return err
+	}
+	if *offset < 0 {
+		*offset = e.Next()
+	}
+	if err := k.Consume(*id, *offset, *length); err != nil {
+		return fmt.Errorf("using key %v: %w", *id, err)
+	}
+	if err := c.writeKeyring(f, *k, protected); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.stdout, *offset)
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+func TestKeyringImportListExport(t *testing.T) {
+	dir := t.TempDir()
+	keyringPath := filepath.Join(dir, "keyring.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	key := `-----BEGIN OTP-----
+Label: lunch plans
+Partner: bob
+
+AQIDBAU=
+-----END OTP-----
+`
+	if err := os.WriteFile(keyPath, []byte(key), 0600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+	now := func() time.Time {
+		return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	}
+	stdin := strings.NewReader(`-----BEGIN OTP-----
+BgcICQo=
+-----END OTP-----
+`)
+	var stdout bytes.Buffer
+	c := command{
+		stdin:  stdin,
+		stdout: &stdout,
+		now:    now,
+	}
+	if err := c.run([]string{"keyring", "import", "-keyring", keyringPath, keyPath, "-"}); err != nil {
+		t.Fatalf("importing keys: %v", err)
+	}
+	if err := c.run([]string{"keyring", "import", "-keyring", keyringPath, keyPath}); err == nil {
+		t.Errorf("wanted error importing duplicate key")
+	}
+	emailPath := filepath.Join(dir, "email.txt")
+	if err := os.WriteFile(emailPath, []byte("Here is the key:\n"+key), 0600); err != nil {
+		t.Fatalf("writing email: %v", err)
+	}
+	if err := c.run([]string{"keyring", "import", "-keyring", keyringPath, "-strict", emailPath}); !errors.Is(err, otp.ErrLeadingData) {
+		t.Errorf("wanted leading data error importing email strictly, got %v", err)
+	}
+	if err := c.run([]string{"keyring", "list", "-keyring", keyringPath}); err != nil {
+		t.Fatalf("listing keys: %v", err)
+	}
+	list := stdout.String()
+	lines := strings.Split(strings.TrimSpace(list), "\n")
+	switch {
+	case len(lines) != 3:
+		t.Fatalf("wanted header and two keys, got:\n%v", list)
+	case !strings.Contains(lines[1], "lunch plans"), !strings.Contains(lines[1], "bob"), !strings.Contains(lines[1], "2026-01-02 03:04:05"):
+		t.Errorf("wanted imported key details, got %v", lines[1])
+	}
+	id := strings.Fields(lines[1])[0]
+	stdout.Reset()
+	if err := c.run([]string{"keyring", "export", "-keyring", keyringPath, "-id", id}); err != nil {
+		t.Fatalf("exporting key: %v", err)
+	}
+	if key != stdout.String() {
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", key, stdout.String())
+	}
+	stdout.Reset()
+	if err := c.run([]string{"keyring", "consume", "-keyring", keyringPath, "-id", id, "-length", "2"}); err != nil {
+		t.Fatalf("consuming key: %v", err)
+	}
+	if want, got := "0\n", stdout.String(); want != got {
+		t.Errorf("wanted offset %q, got %q", want, got)
+	}
+	if err := c.run([]string{"keyring", "consume", "-keyring", keyringPath, "-id", id, "-length", "4"}); !errors.Is(err, otp.ErrInvalidOffset) {
+		t.Errorf("wanted invalid offset error consuming past the end of the key, got %v", err)
+	}
+	if err := c.run([]string{"keyring", "consume", "-keyring", keyringPath, "-id", id, "-offset", "1", "-length", "1"}); !errors.Is(err, otp.ErrKeyReused) {
+		t.Errorf("wanted key reused error consuming used part of key, got %v", err)
+	}
+	stdout.Reset()
+	if err := c.run([]string{"keyring", "export", "-keyring", keyringPath, "-id", id}); err != nil {
+		t.Fatalf("exporting used key: %v", err)
+	}
+	usedKey := strings.Replace(key, "Partner: bob\n", "Partner: bob\nUsed: 0-2\n", 1)
+	if usedKey != stdout.String() {
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", usedKey, stdout.String())
+	}
+}
@@ -0,0 +1,107 @@
+// Package main manages keys and verifies builds from the command line.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// environmentVariablePassphrase is the environment variable with the passphrase of protected keys.
+const environmentVariablePassphrase = "OTP_PASSPHRASE"
+
+// main runs the command in the arguments.
+func main() {
+	c := command{
+		stdin:     os.Stdin,
+		stdout:    os.Stdout,
+		now:       time.Now,
+		lookupEnv: os.LookupEnv,
+	}
+	if err := c.run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// errUsage is returned when the arguments do not name a command.
+var errUsage = errors.New(`usage: otp <command> [flags] [files]
+
+commands:
+  key protect      encrypt key files with a passphrase
+  key unprotect    decrypt key files that are protected with a passphrase
+  keyring list     list the keys in a keyring
+  keyring import   add keys from key files or other keyrings to a keyring
+  keyring export   write a key from a keyring as a key file
+  keyring consume  record that part of a key in a keyring was used
+  build verify     compare the files of a server to the files of a local build
+
+The passphrase of protected keys is read from the file of the -passphrase-file flag or the ` + environmentVariablePassphrase + ` environment variable.`)
+
+// command is the environment commands run in.
+type command struct {
+	stdin  io.Reader
+	stdout io.Writer
+	// now is the time keys are added to keyrings.
+	now       func() time.Time
+	lookupEnv func(string) (string, bool)
+}
+
+// run runs the command in the arguments.
+func (c command) run(args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	switch args[0] + " " + args[1] {
+	case "key protect":
+		return c.keyProtect(args[2:])
+	case "key unprotect":
+		return c.keyUnprotect(args[2:])
+	case "keyring list":
+		return c.keyringList(args[2:])
+	case "keyring import":
+		return c.keyringImport(args[2:])
+	case "keyring export":
+		return c.keyringExport(args[2:])
+	case "keyring consume":
+		return c.keyringConsume(args[2:])
+	case "build verify":
+		return c.buildVerify(args[2:])
+	}
+	return errUsage
+}
+
+// passphrase reads the passphrase from the file, or from the environment if the path is empty.
+// A trailing newline in the file is ignored.
+func (c command) passphrase(path string) (string, error) {
+	if len(path) != 0 {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading passphrase: %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	if p, ok := c.lookupEnv(environmentVariablePassphrase); ok && len(p) != 0 {
+		return p, nil
+	}
+	return "", errors.New("passphrase required: use -passphrase-file or set " + environmentVariablePassphrase)
+}
+
+// readInput reads the file with the name, or stdin if the name is "-".
+func (c command) readInput(name string) ([]byte, error) {
+	if name == "-" {
+		b, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading stdin: %w", err)
+		}
+		return b, nil
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading %v: %w", name, err)
+	}
+	return b, nil
+}
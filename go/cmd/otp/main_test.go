@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestCommandRunUsage(t *testing.T) {
+	runTests := [][]string{
+		nil,
+		{"keyring"},
+		{"keyring", "unknown"},
+		{"unknown", "list"},
+	}
+	for i, args := range runTests {
+		var c command
+		if err := c.run(args); err != errUsage {
+			t.Errorf("test %v: wanted usage error, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/server"
+)
+
+// newBranding creates the branding of the site from the branding file and flags.
+// Flags replace the values of the file.  Values that are not set are replaced with the server defaults.
+func newBranding(m mainFlags) (*server.Branding, error) {
+	var b server.Branding
+	if len(m.BrandingFile) != 0 {
+		data, err := os.ReadFile(m.BrandingFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading branding file: %v", err)
+		}
+		if err := json.Unmarshal(data, &b); err != nil {
+			return nil, fmt.Errorf("parsing branding file: %v", err)
+		}
+	}
+	for _, f := range []struct {
+		value *string
+		flag  string
+	}{
+		{&b.Name, m.Name},
+		{&b.ShortName, m.ShortName},
+		{&b.Description, m.Description},
+		{&b.ThemeColor, m.ThemeColor},
+		{&b.BackgroundColor, m.Background},
+	} {
+		if len(f.flag) != 0 {
+			*f.value = f.flag
+		}
+	}
+	if len(m.Links) != 0 {
+		links, err := parseLinks(m.Links)
+		if err != nil {
+			return nil, err
+		}
+		b.Links = links
+	}
+	return &b, nil
+}
+
+// parseLinks parses text=url links on separate lines.
+// Lines are used because urls can have commas, but not unescaped newlines.  Blank lines are ignored.
+func parseLinks(s string) ([]server.Link, error) {
+	var links []server.Link
+	for _, l := range strings.Split(s, "\n") {
+		if len(strings.TrimSpace(l)) == 0 {
+			continue
+		}
+		text, url, ok := strings.Cut(strings.TrimSpace(l), "=")
+		if !ok {
+			return nil, fmt.Errorf("link not in text=url format: %q", l)
+		}
+		link := server.Link{
+			Text: strings.TrimSpace(text),
+			URL:  strings.TrimSpace(url),
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/server"
+)
+
+func TestNewBranding(t *testing.T) {
+	brandingFile := filepath.Join(t.TempDir(), "branding.json")
+	data := `{"name": "Example OTP", "themeColor": "navy", "links": [{"text": "Intranet", "url": "https://intranet.example.com"}]}`
+	if err := os.WriteFile(brandingFile, []byte(data), 0600); err != nil {
+		t.Fatalf("writing branding file: %v", err)
+	}
+	newBrandingTests := []struct {
+		m      mainFlags
+		wantOk bool
+		want   server.Branding
+	}{
+		{
+			wantOk: true,
+		},
+		{
+			m: mainFlags{
+				BrandingFile: brandingFile,
+				ThemeColor:   "teal",
+			},
+			wantOk: true,
+			want: server.Branding{
+				Name:       "Example OTP",
+				ThemeColor: "teal",
+				Links: []server.Link{
+					{Text: "Intranet", URL: "https://intranet.example.com"},
+				},
+			},
+		},
+		{
+			m: mainFlags{
+				BrandingFile: brandingFile,
+				Links:        "Help=https://example.com/help?a=b,c\n\n Status=https://status.example.com\n",
+			},
+			wantOk: true,
+			want: server.Branding{
+				Name:       "Example OTP",
+				ThemeColor: "navy",
+				Links: []server.Link{
+					{Text: "Help", URL: "https://example.com/help?a=b,c"},
+					{Text: "Status", URL: "https://status.example.com"},
+				},
+			},
+		},
+		{
+			m: mainFlags{
+				BrandingFile: filepath.Join(t.TempDir(), "missing.json"),
+			},
+		},
+		{
+			m: mainFlags{
+				Links: "https://example.com",
+			},
+		},
+	}
+	for i, test := range newBrandingTests {
+		got, err := newBranding(test.m)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case !reflect.DeepEqual(test.want, *got):
+			t.Errorf("test %v:\nwanted: %v\ngot:    %v", i, test.want, *got)
+		}
+	}
+}
@@ -5,16 +5,53 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/server"
 )
 
 const (
 	environmentVariableVersionFile = "VERSION_FILE"
 	environmentVariablePort        = "PORT"
+	environmentVariableMailbox     = "MAILBOX"
+	environmentVariableMailboxDir  = "MAILBOX_DIR"
+	environmentVariableMailboxSize = "MAILBOX_MAX_SIZE"
+	environmentVariableMailboxTTL  = "MAILBOX_TTL"
+	environmentVariableChat        = "CHAT"
+	environmentVariableTLSCert     = "TLS_CERT_FILE"
+	environmentVariableTLSKey      = "TLS_KEY_FILE"
+	environmentVariableSelfSigned  = "TLS_SELF_SIGNED"
+	environmentVariableHTTPPort    = "HTTP_REDIRECT_PORT"
+	environmentVariableCSP         = "CONTENT_SECURITY_POLICY"
+	environmentVariableBranding    = "BRANDING_FILE"
+	environmentVariableName        = "SITE_NAME"
+	environmentVariableShortName   = "SITE_SHORT_NAME"
+	environmentVariableDescription = "SITE_DESCRIPTION"
+	environmentVariableThemeColor  = "THEME_COLOR"
+	environmentVariableBackground  = "BACKGROUND_COLOR"
+	environmentVariableLinks       = "SITE_LINKS"
 )
 
 // mainFlags are the configuration options for different environments.
 type mainFlags struct {
-	Port int
+	Port           int
+	Mailbox        string
+	MailboxDir     string
+	MailboxMaxSize int
+	MailboxTTL     time.Duration
+	Chat           bool
+	TLSCertFile    string
+	TLSKeyFile     string
+	TLSSelfSigned  bool
+	HTTPPort       int
+	CSP            string
+	BrandingFile   string
+	Name           string
+	ShortName      string
+	Description    string
+	ThemeColor     string
+	Background     string
+	Links          string
 }
 
 // newMainFlags creates a new, populated mainFlags structure.
@@ -51,7 +88,40 @@ func (m *mainFlags) newFlagSet(osLookupEnvFunc func(string) (string, bool)) *fla
 		}
 		return v2
 	}
+	envValueBool := func(key string, defaultValue bool) bool {
+		v1 := envValue(key, "")
+		v2, err := strconv.ParseBool(v1)
+		if err != nil {
+			return defaultValue
+		}
+		return v2
+	}
+	envValueDuration := func(key string, defaultValue time.Duration) time.Duration {
+		v1 := envValue(key, "")
+		v2, err := time.ParseDuration(v1)
+		if err != nil {
+			return defaultValue
+		}
+		return v2
+	}
 	fs.IntVar(&m.Port, "port", envValueInt(environmentVariablePort, 8080), "The port for server http requests.")
+	fs.StringVar(&m.Mailbox, "mailbox", envValue(environmentVariableMailbox, ""), "The storage of the mailbox API that holds cipher text until it is read once: memory or file. The API is disabled if empty.")
+	fs.StringVar(&m.MailboxDir, "mailbox-dir", envValue(environmentVariableMailboxDir, "mailboxes"), "The directory of file mailboxes.")
+	fs.IntVar(&m.MailboxMaxSize, "mailbox-max-size", envValueInt(environmentVariableMailboxSize, server.DefaultMailboxMaxSize), "The maximum number of bytes of cipher text in a mailbox.")
+	fs.DurationVar(&m.MailboxTTL, "mailbox-ttl", envValueDuration(environmentVariableMailboxTTL, server.DefaultMailboxTTL), "How long mailboxes are kept before they expire.")
+	fs.StringVar(&m.TLSCertFile, "tls-cert", envValue(environmentVariableTLSCert, ""), "The PEM file of the tls certificate.  The server uses https if it and the key are set.")
+	fs.StringVar(&m.TLSKeyFile, "tls-key", envValue(environmentVariableTLSKey, ""), "The PEM file of the private key of the tls certificate.")
+	fs.BoolVar(&m.TLSSelfSigned, "tls-self-signed", envValueBool(environmentVariableSelfSigned, false), "Creates a self-signed tls certificate and key for local networks if the tls files do not exist.")
+	fs.IntVar(&m.HTTPPort, "http-redirect-port", envValueInt(environmentVariableHTTPPort, 0), "The port for http requests that are redirected to https when tls is enabled.  Requests are not redirected if zero.")
+	fs.BoolVar(&m.Chat, "chat", envValueBool(environmentVariableChat, false), "Relays encrypted chat messages between the participants of chat rooms.")
+	fs.StringVar(&m.CSP, "content-security-policy", envValue(environmentVariableCSP, ""), "Replaces the default Content-Security-Policy header.  {nonce} is replaced with the nonce of inline scripts and styles.")
+	fs.StringVar(&m.BrandingFile, "branding-file", envValue(environmentVariableBranding, ""), "A json file with the name, shortName, description, themeColor, backgroundColor, and links of the site.  Other branding flags replace its values.")
+	fs.StringVar(&m.Name, "name", envValue(environmentVariableName, ""), "The name of the site.")
+	fs.StringVar(&m.ShortName, "short-name", envValue(environmentVariableShortName, ""), "The short name of the site, for app icons.  Its first letter is drawn on the icon.")
+	fs.StringVar(&m.Description, "description", envValue(environmentVariableDescription, ""), "The description of the site.")
+	fs.StringVar(&m.ThemeColor, "theme-color", envValue(environmentVariableThemeColor, ""), "The CSS color of the icon and browser toolbar.")
+	fs.StringVar(&m.Background, "background-color", envValue(environmentVariableBackground, ""), "The CSS color of the background of the icon.")
+	fs.StringVar(&m.Links, "links", envValue(environmentVariableLinks, ""), "Text=url links for the about tab on separate lines, such as \"Help=https://example.com/help\".")
 	return fs
 }
 
@@ -60,6 +130,23 @@ func usage(fs *flag.FlagSet) {
 	envVars := []string{
 		environmentVariableVersionFile,
 		environmentVariablePort,
+		environmentVariableMailbox,
+		environmentVariableMailboxDir,
+		environmentVariableMailboxSize,
+		environmentVariableMailboxTTL,
+		environmentVariableChat,
+		environmentVariableTLSCert,
+		environmentVariableTLSKey,
+		environmentVariableSelfSigned,
+		environmentVariableHTTPPort,
+		environmentVariableCSP,
+		environmentVariableBranding,
+		environmentVariableName,
+		environmentVariableShortName,
+		environmentVariableDescription,
+		environmentVariableThemeColor,
+		environmentVariableBackground,
+		environmentVariableLinks,
 	}
 	fmt.Fprintf(fs.Output(), "Runs the server\n")
 	fmt.Fprintf(fs.Output(), "Reads environment variables when possible: [%s]\n", strings.Join(envVars, ","))
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewMainFlags(t *testing.T) {
@@ -17,18 +18,86 @@ func TestNewMainFlags(t *testing.T) {
 			osArgs: []string{
 				"ignored-binary-name",
 				"-port=1",
+				"-mailbox=file",
+				"-mailbox-dir=/tmp/mail",
+				"-mailbox-max-size=2",
+				"-mailbox-ttl=3m",
+				"-chat",
+				"-tls-cert=cert.pem",
+				"-tls-key=key.pem",
+				"-tls-self-signed",
+				"-http-redirect-port=80",
+				"-content-security-policy=default-src 'self'",
+				"-branding-file=branding.json",
+				"-name=Example",
+				"-short-name=Ex",
+				"-description=an example",
+				"-theme-color=navy",
+				"-background-color=black",
+				"-links=Help=https://example.com/help",
 			},
 			want: mainFlags{
-				Port: 1,
+				Port:           1,
+				Mailbox:        "file",
+				MailboxDir:     "/tmp/mail",
+				MailboxMaxSize: 2,
+				MailboxTTL:     3 * time.Minute,
+				Chat:           true,
+				TLSCertFile:    "cert.pem",
+				TLSKeyFile:     "key.pem",
+				TLSSelfSigned:  true,
+				HTTPPort:       80,
+				CSP:            "default-src 'self'",
+				BrandingFile:   "branding.json",
+				Name:           "Example",
+				ShortName:      "Ex",
+				Description:    "an example",
+				ThemeColor:     "navy",
+				Background:     "black",
+				Links:          "Help=https://example.com/help",
 			},
 		},
 		{ // all environment variables
 			envVars: map[string]string{
-				"VERSION_FILE": "0",
-				"PORT":         "1",
+				"VERSION_FILE":            "0",
+				"PORT":                    "1",
+				"MAILBOX":                 "memory",
+				"MAILBOX_DIR":             "/tmp/mail",
+				"MAILBOX_MAX_SIZE":        "2",
+				"MAILBOX_TTL":             "3m",
+				"CHAT":                    "true",
+				"TLS_CERT_FILE":           "cert.pem",
+				"TLS_KEY_FILE":            "key.pem",
+				"TLS_SELF_SIGNED":         "true",
+				"HTTP_REDIRECT_PORT":      "80",
+				"CONTENT_SECURITY_POLICY": "default-src 'self'",
+				"BRANDING_FILE":           "branding.json",
+				"SITE_NAME":               "Example",
+				"SITE_SHORT_NAME":         "Ex",
+				"SITE_DESCRIPTION":        "an example",
+				"THEME_COLOR":             "navy",
+				"BACKGROUND_COLOR":        "black",
+				"SITE_LINKS":              "Help=https://example.com/help",
 			},
 			want: mainFlags{
-				Port: 1,
+				Port:           1,
+				Mailbox:        "memory",
+				MailboxDir:     "/tmp/mail",
+				MailboxMaxSize: 2,
+				MailboxTTL:     3 * time.Minute,
+				Chat:           true,
+				TLSCertFile:    "cert.pem",
+				TLSKeyFile:     "key.pem",
+				TLSSelfSigned:  true,
+				HTTPPort:       80,
+				CSP:            "default-src 'self'",
+				BrandingFile:   "branding.json",
+				Name:           "Example",
+				ShortName:      "Ex",
+				Description:    "an example",
+				ThemeColor:     "navy",
+				Background:     "black",
+				Links:          "Help=https://example.com/help",
 			},
 		},
 	}
@@ -60,7 +129,7 @@ func TestUsage(t *testing.T) {
 	got := b.String()
 	b.Reset()
 	fs.PrintDefaults()
-	wantEnvVarCount := 1
+	wantEnvVarCount := 18
 	wantLineCount := 3 + wantEnvVarCount*2 // 3 initial lines, 2 lines per env var
 	gotLineCount := strings.Count(got, "\n")
 	if wantLineCount != gotLineCount {
@@ -29,6 +29,9 @@ var buildFS embed.FS
 //go:embed build/version
 var version string
 
+// maxMailboxes limits the number of mailboxes that are kept in memory or in files.
+const maxMailboxes = 1024
+
 // main creates and runs the server.
 func main() {
 	m := newMainFlags(os.Args, os.LookupEnv)
@@ -43,12 +46,34 @@ func main() {
 
 // create server creates the server from a configuration.
 func createServer(ctx context.Context, m mainFlags, log *log.Logger) (*server.Server, error) {
+	mailboxStore, err := newMailboxStore(m)
+	if err != nil {
+		return nil, err
+	}
+	branding, err := newBranding(m)
+	if err != nil {
+		return nil, err
+	}
 	cfg := server.Config{
-		Log:         log,
-		Version:     version,
-		Port:        m.Port,
-		ResourcesFS: resourcesFS,
-		BuildFS:     buildFS,
+		Log:              log,
+		Version:          version,
+		Port:             m.Port,
+		ResourcesFS:      resourcesFS,
+		BuildFS:          buildFS,
+		MailboxStore:     mailboxStore,
+		MailboxMaxSize:   int64(m.MailboxMaxSize),
+		MailboxTTL:       m.MailboxTTL,
+		Chat:             m.Chat,
+		TLSCertFile:      m.TLSCertFile,
+		TLSKeyFile:       m.TLSKeyFile,
+		TLSSelfSigned:    m.TLSSelfSigned,
+		HTTPRedirectPort: m.HTTPPort,
+		Branding:         *branding,
+	}
+	if len(m.CSP) != 0 {
+		cfg.SecurityHeaders = map[string]string{
+			"Content-Security-Policy": m.CSP,
+		}
 	}
 	server, err := cfg.NewServer()
 	if err != nil {
@@ -57,6 +82,24 @@ func createServer(ctx context.Context, m mainFlags, log *log.Logger) (*server.Se
 	return server, nil
 }
 
+// newMailboxStore creates the storage of the mailbox API, which is nil if the API is disabled.
+func newMailboxStore(m mainFlags) (server.MailboxStore, error) {
+	switch m.Mailbox {
+	case "":
+		return nil, nil
+	case "memory":
+		return server.NewMemoryMailboxStore(maxMailboxes), nil
+	case "file":
+		s, err := server.NewFileMailboxStore(m.MailboxDir, maxMailboxes)
+		if err != nil {
+			return nil, fmt.Errorf("creating mailbox store: %v", err)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown mailbox storage: %q", m.Mailbox)
+	}
+}
+
 // runServer runs the server until it is interrupted or terminated.
 func runServer(ctx context.Context, server server.Server, log *log.Logger) {
 	done := make(chan os.Signal, 2)
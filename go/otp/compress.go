@@ -0,0 +1,62 @@
+package otp
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// compressionHeader is the cipher header that names the compression applied to the message before it was encrypted.
+	compressionHeader = "Compression"
+	// deflateEnglish is the compression header value of messages compressed with DEFLATE using the englishDictionary.
+	deflateEnglish = "deflate-english"
+	// maxDecompressedLength is the most bytes a compressed message is allowed to decompress to.
+	maxDecompressedLength = 1 << 20
+)
+
+// englishDictionary is the preset DEFLATE dictionary for plain English messages.
+// Common words are last because they are closest to the compressed text.
+// The dictionary must never change, or previously compressed messages cannot be decompressed.
+const englishDictionary = "please thank you tomorrow today tonight morning evening meeting " +
+	"message received understood confirm address location time place when where what why how " +
+	"could would should about after before because there their they them then than this that these those " +
+	"have has had been were was will with from your you are not but for and the of to in is it " +
+	"I a at be by do go if me my no on or so up us we "
+
+// compress compresses the message with DEFLATE using the English dictionary.
+func compress(message []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriterDict(&buf, flate.BestCompression, []byte(englishDictionary))
+	if err != nil {
+		return nil, fmt.Errorf("creating compressor: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return nil, fmt.Errorf("compressing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("finishing compression: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress decompresses the message using the algorithm from the compression header.
+// Bytes after the end of the compressed data, such as unused key bytes, are ignored.
+func decompress(message []byte, compression string) ([]byte, error) {
+	if compression != deflateEnglish {
+		return nil, errors.New("unknown compression: " + compression)
+	}
+	r := flate.NewReaderDict(bytes.NewReader(message), []byte(englishDictionary))
+	defer r.Close()
+	lr := io.LimitReader(r, maxDecompressedLength+1)
+	m, err := io.ReadAll(lr)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("decompressing message: %w", err)
+	case len(m) > maxDecompressedLength:
+		return nil, errors.New("decompressed message too large")
+	}
+	return m, nil
+}
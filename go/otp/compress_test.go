@@ -0,0 +1,137 @@
+package otp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	compressTests := []string{
+		"",
+		"a",
+		"please confirm the meeting location for tomorrow morning",
+		strings.Repeat("x", maxDecompressedLength),
+	}
+	for i, want := range compressTests {
+		c, err := compress([]byte(want))
+		if err != nil {
+			t.Errorf("test %v: unwanted compress error: %v", i, err)
+			continue
+		}
+		c = append(c, 0, 0, 0) // unused key bytes
+		got, err := decompress(c, deflateEnglish)
+		switch {
+		case err != nil:
+			t.Errorf("test %v: unwanted decompress error: %v", i, err)
+		case want != string(got):
+			t.Errorf("test %v: not equal\nwanted: %q\ngot:    %q", i, want, string(got))
+		}
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	tooLarge, err := compress([]byte(strings.Repeat("x", maxDecompressedLength+1)))
+	if err != nil {
+		t.Fatalf("unwanted compress error: %v", err)
+	}
+	decompressTests := []struct {
+		message     []byte
+		compression string
+	}{
+		{
+			compression: "unknown",
+		},
+		{ // not compressed
+			message:     []byte{0xff, 0xff, 0xff},
+			compression: deflateEnglish,
+		},
+		{
+			message:     tooLarge,
+			compression: deflateEnglish,
+		},
+	}
+	for i, test := range decompressTests {
+		if _, err := decompress(test.message, test.compression); err == nil {
+			t.Errorf("test %v: wanted error", i)
+		}
+	}
+}
+
+func TestCompressedEncryptDecrypt(t *testing.T) {
+	message := "please confirm the meeting location for tomorrow morning"
+	key := `-----BEGIN OTP-----
+AQIDBAUGBwgJAQIDBAUGBwgJAQIDBAUGBwgJAQIDBAUGBwgJAQIDBAUGBwgJAQIDBAUGBwgJ
+-----END OTP-----
+`
+	opts := Options{
+		Compress: true,
+	}
+	padLength, err := opts.PadLength(message)
+	switch {
+	case err != nil:
+		t.Fatalf("unwanted pad length error: %v", err)
+	case padLength >= len(message):
+		t.Errorf("wanted compressed message to use fewer than %v key bytes, got %v", len(message), padLength)
+	}
+	cipher, err := opts.Encrypt(message, []byte(key))
+	switch {
+	case err != nil:
+		t.Fatalf("unwanted encrypt error: %v", err)
+	case !strings.Contains(string(cipher), compressionHeader+": "+deflateEnglish):
+		t.Errorf("wanted compression header in cipher, got:\n%s", cipher)
+	}
+	got, err := Decrypt(string(cipher), []byte(key))
+	switch {
+	case err != nil:
+		t.Errorf("unwanted decrypt error: %v", err)
+	case message != string(got):
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", message, string(got))
+	}
+}
+
+func TestPadLength(t *testing.T) {
+	padLengthTests := []struct {
+		message string
+		opts    Options
+		want    int
+		wantOk  bool
+	}{
+		{
+			message: "CAT",
+			want:    3,
+			wantOk:  true,
+		},
+		{
+			message: "héllo",
+			want:    6,
+			wantOk:  true,
+		},
+		{ // compression not used when it would make the message longer
+			message: "\x01\x02\x03",
+			opts: Options{
+				Compress: true,
+			},
+			want:   3,
+			wantOk: true,
+		},
+		{
+			message: "CAT",
+			opts: Options{
+				Offset: -1,
+			},
+		},
+	}
+	for i, test := range padLengthTests {
+		got, err := test.opts.PadLength(test.message)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != got:
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, got)
+		}
+	}
+}
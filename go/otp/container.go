@@ -0,0 +1,200 @@
+package otp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// containerHeader is the cipher header that marks messages that are serialized containers.
+	containerHeader = "Container"
+	// containerVersion is the first byte of serialized containers.
+	containerVersion = 1
+)
+
+type (
+	// Container is a message with a text body and attachments.
+	// It is serialized before it is encrypted so the body and attachments are encrypted as one message.
+	Container struct {
+		// Body is the text of the message.
+		Body string
+		// Attachments are the files sent with the message.
+		Attachments []Attachment
+	}
+
+	// Attachment is a named file in a container.
+	Attachment struct {
+		// Name is the file name of the attachment.
+		Name string
+		// Type is the MIME type of the attachment, such as image/png.
+		Type string
+		// Data is the content of the file.
+		Data []byte
+	}
+)
+
+// MarshalBinary serializes the container.
+// Each string and byte slice is prefixed with its length.
+func (c Container) MarshalBinary() ([]byte, error) {
+	b := []byte{containerVersion}
+	b = appendBytes(b, []byte(c.Body))
+	b = binary.AppendUvarint(b, uint64(len(c.Attachments)))
+	for i, a := range c.Attachments {
+		if len(a.Name) == 0 {
+			return nil, errors.New("attachment " + strconv.Itoa(i) + " must have a name")
+		}
+		b = appendBytes(b, []byte(a.Name))
+		b = appendBytes(b, []byte(a.Type))
+		b = appendBytes(b, a.Data)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary deserializes the container.
+// Zero bytes after the container, such as those decrypted with the unused part of a whole key, are ignored.
+func (c *Container) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 || b[0] != containerVersion {
+		return errors.New("unknown container version")
+	}
+	r := containerReader{b: b[1:]}
+	body := r.next()
+	n := r.uvarint()
+	if r.err == nil && n > uint64(len(r.b)) { // each attachment has at least one byte
+		return errors.New("too many attachments: " + strconv.FormatUint(n, 10))
+	}
+	attachments := make([]Attachment, 0, n)
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		a := Attachment{
+			Name: string(r.next()),
+			Type: string(r.next()),
+			Data: bytes.Clone(r.next()),
+		}
+		attachments = append(attachments, a)
+	}
+	if r.err != nil {
+		return fmt.Errorf("reading container: %w", r.err)
+	}
+	for _, x := range r.b {
+		if x != 0 {
+			return errors.New("extra data after container")
+		}
+	}
+	c.Body = string(body)
+	c.Attachments = attachments
+	return nil
+}
+
+// EncryptContainer serializes and encrypts the container using the key and options to produce the cipher text.
+// The cipher text is marked as a container so DecryptContainer can deserialize it.
+func (opts Options) EncryptContainer(c Container, key []byte) ([]byte, error) {
+	k, err := ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Destroy()
+	return k.EncryptContainer(opts, c)
+}
+
+// EncryptContainer serializes and encrypts the container with the key and options to produce the cipher text.
+func (k *Key) EncryptContainer(opts Options, c Container) ([]byte, error) {
+	b, err := c.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	defer clear(b)
+	opts.container = true
+	return k.Encrypt(opts, b)
+}
+
+// ContainerPadLength is the number of key bytes that are used to encrypt the container with the options.
+func (opts Options) ContainerPadLength(c Container) (int, error) {
+	b, err := c.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	defer clear(b)
+	p, _, err := opts.payload(b)
+	if err != nil {
+		return 0, err
+	}
+	defer clear(p)
+	return len(p), nil
+}
+
+// DecryptContainer decrypts the cipher text using the key to produce a container.
+// Cipher text that is not marked as a container is decrypted into the body of a container without attachments.
+func DecryptContainer(cipher string, key []byte) (*Container, error) {
+	k, err := ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Destroy()
+	return k.DecryptContainer([]byte(cipher))
+}
+
+// DecryptContainer decrypts the cipher text with the key to produce a container.
+func (k *Key) DecryptContainer(cipher []byte) (*Container, error) {
+	m, err := k.Decrypt(cipher)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(m)
+	opts, err := ParseOptions(string(cipher))
+	if err != nil {
+		return nil, err
+	}
+	var c Container
+	if !opts.container {
+		c.Body = string(m)
+		return &c, nil
+	}
+	if err := c.UnmarshalBinary(m); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// appendBytes appends the length of the data and the data to the byte slice.
+func appendBytes(b, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// containerReader reads length-prefixed values from serialized containers.
+// Reading stops at the first error.
+type containerReader struct {
+	b   []byte
+	err error
+}
+
+// uvarint reads a length.
+func (r *containerReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errors.New("invalid length")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// next reads a length-prefixed byte slice.
+func (r *containerReader) next() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.b)) {
+		r.err = errors.New("length longer than remaining data: " + strconv.FormatUint(n, 10))
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
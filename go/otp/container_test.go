@@ -0,0 +1,141 @@
+package otp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContainerBinary(t *testing.T) {
+	containerTests := []Container{
+		{},
+		{
+			Body: "see attached",
+			Attachments: []Attachment{
+				{
+					Name: "map.png",
+					Type: "image/png",
+					Data: []byte{0x89, 'P', 'N', 'G', 0},
+				},
+				{
+					Name: "notes.txt",
+					Data: []byte{},
+				},
+			},
+		},
+	}
+	for i, want := range containerTests {
+		b, err := want.MarshalBinary()
+		if err != nil {
+			t.Errorf("test %v: unwanted marshal error: %v", i, err)
+			continue
+		}
+		b = append(b, 0, 0) // unused part of whole key
+		var got Container
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Errorf("test %v: unwanted unmarshal error: %v", i, err)
+			continue
+		}
+		if want.Attachments == nil {
+			want.Attachments = []Attachment{}
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, want, got)
+		}
+	}
+}
+
+func TestContainerMarshalBinaryNoName(t *testing.T) {
+	c := Container{
+		Attachments: []Attachment{
+			{
+				Data: []byte("data"),
+			},
+		},
+	}
+	if _, err := c.MarshalBinary(); err == nil {
+		t.Errorf("wanted error for attachment without name")
+	}
+}
+
+func TestContainerUnmarshalBinary(t *testing.T) {
+	unmarshalTests := [][]byte{
+		nil,
+		{2},                                    // unknown version
+		{containerVersion, 5, 'a'},             // body too short
+		{containerVersion, 0, 200},             // too many attachments
+		{containerVersion, 0, 1, 1, 'a', 0, 9}, // data too short
+		{containerVersion, 1, 'a', 0, 'x'},     // extra data
+		{containerVersion, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // invalid length
+	}
+	for i, b := range unmarshalTests {
+		var c Container
+		if err := c.UnmarshalBinary(b); err == nil {
+			t.Errorf("test %v: wanted error", i)
+		}
+	}
+}
+
+func TestEncryptDecryptContainer(t *testing.T) {
+	opts := KeyOptions{
+		Rand: NewInsecureReader([]byte("container")),
+	}
+	key, err := opts.GenerateKey(100)
+	if err != nil {
+		t.Fatalf("unwanted key error: %v", err)
+	}
+	want := Container{
+		Body: "see attached",
+		Attachments: []Attachment{
+			{
+				Name: "a.txt",
+				Type: "text/plain",
+				Data: []byte("attached text"),
+			},
+		},
+	}
+	encryptOpts := Options{
+		Offset: 10,
+	}
+	padLength, err := encryptOpts.ContainerPadLength(want)
+	if err != nil {
+		t.Fatalf("unwanted pad length error: %v", err)
+	}
+	cipher, err := encryptOpts.EncryptContainer(want, key)
+	if err != nil {
+		t.Fatalf("unwanted encrypt error: %v", err)
+	}
+	got, err := DecryptContainer(string(cipher), key)
+	switch {
+	case err != nil:
+		t.Errorf("unwanted decrypt error: %v", err)
+	case !reflect.DeepEqual(want, *got):
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", want, *got)
+	}
+	c, err := decodeBlock(cipher)
+	switch {
+	case err != nil:
+		t.Errorf("unwanted decode error: %v", err)
+	case padLength != len(c.Bytes):
+		t.Errorf("wanted pad length to be %v, got %v", len(c.Bytes), padLength)
+	}
+}
+
+func TestDecryptContainerNotContainer(t *testing.T) {
+	// 12345 :
+	key := `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`
+	cipher, err := Encrypt("CAT", []byte(key))
+	if err != nil {
+		t.Fatalf("unwanted encrypt error: %v", err)
+	}
+	want := "CAT\x00\x00"
+	got, err := DecryptContainer(string(cipher), []byte(key))
+	switch {
+	case err != nil:
+		t.Errorf("unwanted decrypt error: %v", err)
+	case want != got.Body, len(got.Attachments) != 0:
+		t.Errorf("wanted container with only body of %q, got %v", want, *got)
+	}
+}
@@ -0,0 +1,67 @@
+package otp
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Errors returned by the package, usually wrapped with more details.
+// Check for them with errors.Is.
+var (
+	// ErrNoPEM is returned when text to decode has no PEM data.
+	ErrNoPEM = errors.New("no PEM data to decode")
+	// ErrLeadingData is returned when text to strictly decode has more text before the PEM data.
+	ErrLeadingData = errors.New("extra text before PEM data")
+	// ErrTrailingData is returned when text to strictly decode has more text after the PEM data.
+	ErrTrailingData = errors.New("extra text after PEM data")
+	// ErrMultipleBlocks is returned when text to decode has more than one OTP block.
+	ErrMultipleBlocks = errors.New("more than one OTP block")
+	// ErrInvalidBlock is returned when an OTP block in a bundle cannot be decoded.
+	ErrInvalidBlock = errors.New("OTP block cannot be decoded")
+	// ErrMessageTooLong is returned when the message needs more bytes than the key has.
+	ErrMessageTooLong = errors.New("message must not be longer than key")
+	// ErrCipherTooLong is returned when the cipher text is longer than the key that should decrypt it.
+	ErrCipherTooLong = errors.New("cipher text must not be longer than key")
+	// ErrKeyTooSmall is returned when creating a key without any bytes.
+	ErrKeyTooSmall = errors.New("key must have positive number of characters")
+	// ErrKeyTooLarge is returned when creating a key that is longer than MaxKeyLength.
+	ErrKeyTooLarge = errors.New("key length too large")
+	// ErrInvalidOffset is returned when the offset is not inside the key.
+	ErrInvalidOffset = errors.New("offset must be inside of key")
+	// ErrNotGroupKey is returned when a group key is needed but the key has no members.
+	ErrNotGroupKey = errors.New("key is not a group key")
+	// ErrUnknownMember is returned when the sender of a message is not a member of the group key.
+	ErrUnknownMember = errors.New("member not in group key")
+	// ErrWrongPassphrase is returned when sealed data cannot be opened with the lock.
+	ErrWrongPassphrase = errors.New("wrong passphrase or modified data")
+	// ErrProtectedKey is returned when using a key that is protected by a passphrase before it is unprotected.
+	ErrProtectedKey = errors.New("key is protected by a passphrase")
+	// ErrDestroyedKey is returned when using a key after it is destroyed.
+	ErrDestroyedKey = errors.New("key destroyed")
+	// ErrKeyNotFound is returned when the keyring has no key with an id.
+	ErrKeyNotFound = errors.New("key not in keyring")
+	// ErrDuplicateKey is returned when adding a key that is already in the keyring.
+	ErrDuplicateKey = errors.New("key already in keyring")
+	// ErrKeyReused is returned when consuming part of a key that was already used.
+	ErrKeyReused = errors.New("part of key already used")
+)
+
+// LengthError is returned when a message or cipher text needs more bytes of the key than are available.
+// It wraps ErrMessageTooLong or ErrCipherTooLong.
+type LengthError struct {
+	Err error
+	// Length is the number of key bytes that are needed.
+	Length int
+	// Capacity is the number of key bytes that are available.
+	Capacity int
+}
+
+// Error describes how many bytes are needed.
+func (e *LengthError) Error() string {
+	return e.Err.Error() + ": needs " + strconv.Itoa(e.Length) + " bytes, but only " + strconv.Itoa(e.Capacity) + " are available"
+}
+
+// Unwrap returns the sentinel error.
+func (e *LengthError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,112 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	key := `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`
+	errorTests := []struct {
+		f    func() error
+		want error
+	}{
+		{
+			f: func() error {
+				_, err := Encrypt("CATASTROPHE", []byte(key))
+				return err
+			},
+			want: ErrMessageTooLong,
+		},
+		{
+			f: func() error {
+				_, err := Decrypt(`-----BEGIN OTP-----
+Sender: alice
+
+QkM=
+-----END OTP-----
+`, []byte(key))
+				return err
+			},
+			want: ErrNotGroupKey,
+		},
+		{
+			f: func() error {
+				_, err := Decrypt("", []byte(key))
+				return err
+			},
+			want: ErrNoPEM,
+		},
+		{
+			f: func() error {
+				return ValidateStrict([]byte(key + "extra"))
+			},
+			want: ErrTrailingData,
+		},
+		{
+			f: func() error {
+				return ValidateStrict([]byte("extra\n" + key))
+			},
+			want: ErrLeadingData,
+		},
+		{
+			f: func() error {
+				_, err := Encrypt("CAT", []byte(key+key))
+				return err
+			},
+			want: ErrMultipleBlocks,
+		},
+		{
+			f: func() error {
+				_, err := GenerateKey(MaxKeyLength + 1)
+				return err
+			},
+			want: ErrKeyTooLarge,
+		},
+		{
+			f: func() error {
+				_, err := GenerateKey(0)
+				return err
+			},
+			want: ErrKeyTooSmall,
+		},
+		{
+			f: func() error {
+				_, err := Options{Offset: 6}.Encrypt("C", []byte(key))
+				return err
+			},
+			want: ErrInvalidOffset,
+		},
+		{
+			f: func() error {
+				_, err := EncryptGroup("CAT", []byte(groupKey), "dave", 0)
+				return err
+			},
+			want: ErrUnknownMember,
+		},
+	}
+	for i, test := range errorTests {
+		err := test.f()
+		if !errors.Is(err, test.want) {
+			t.Errorf("test %v: wanted error to be %v, got %v", i, test.want, err)
+		}
+	}
+}
+
+func TestLengthError(t *testing.T) {
+	key := `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`
+	_, err := Options{Offset: 2}.Encrypt("CATS", []byte(key))
+	var lengthErr *LengthError
+	switch {
+	case !errors.As(err, &lengthErr):
+		t.Errorf("wanted length error, got %v", err)
+	case lengthErr.Length != 4, lengthErr.Capacity != 3:
+		t.Errorf("wanted to need 4 bytes with 3 available, got %v", lengthErr)
+	}
+}
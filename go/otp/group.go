@@ -0,0 +1,122 @@
+package otp
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// membersHeader is the key header that lists the members of a group key in the order of their regions.
+	membersHeader = "Members"
+	// senderHeader is the cipher header that names the group member whose region encrypted the message.
+	senderHeader = "Sender"
+	// offsetHeader is the cipher header with the index of the first key byte of the region that encrypted the message.
+	offsetHeader = "Offset"
+	// memberSeparator separates the names in the members header.
+	memberSeparator = ","
+)
+
+// GenerateGroupKey creates an encoded key with a separate region of regionLength bytes for each member.
+// Members only encrypt messages with their own region, but can decrypt messages from any member.
+func GenerateGroupKey(members []string, regionLength int) ([]byte, error) {
+	return KeyOptions{}.GenerateGroupKey(members, regionLength)
+}
+
+// EncryptGroup encrypts the message with the sender's region of the group key, starting at the offset of the region.
+// The cipher text records the sender and offset so other members can decrypt it.
+// Senders should increase the offset by the length of each message to never reuse part of their region.
+func EncryptGroup(message string, key []byte, sender string, offset int) ([]byte, error) {
+	if len(sender) == 0 {
+		return nil, errors.New("sender required to encrypt with group key")
+	}
+	opts := Options{
+		Sender: sender,
+		Offset: offset,
+	}
+	return opts.Encrypt(message, key)
+}
+
+// GroupMembers lists the members of the group key.
+func GroupMembers(key []byte) ([]string, error) {
+	k, err := decodeBlock(key)
+	if err != nil {
+		return nil, fmt.Errorf("decoding key: %w", err)
+	}
+	clear(k.Bytes)
+	members := members(k)
+	if len(members) == 0 {
+		return nil, ErrNotGroupKey
+	}
+	return members, nil
+}
+
+// pad returns the part of the key that was used to encrypt the cipher.
+func pad(cipher, key *pem.Block) ([]byte, error) {
+	p := key.Bytes
+	if sender, ok := cipher.Headers[senderHeader]; ok {
+		r, err := region(key, sender)
+		if err != nil {
+			return nil, err
+		}
+		p = r
+	}
+	if o, ok := cipher.Headers[offsetHeader]; ok {
+		offset, err := strconv.Atoi(o)
+		switch {
+		case err != nil:
+			return nil, fmt.Errorf("parsing cipher offset: %w", err)
+		case offset < 0, offset > len(p):
+			return nil, fmt.Errorf("%w: %s", ErrInvalidOffset, o)
+		}
+		p = p[offset:]
+	}
+	return p, nil
+}
+
+// region returns the bytes of the key that belong to the member.
+func region(key *pem.Block, member string) ([]byte, error) {
+	members := members(key)
+	if len(members) == 0 {
+		return nil, ErrNotGroupKey
+	}
+	n := len(key.Bytes) / len(members)
+	for i, m := range members {
+		if m == member {
+			return key.Bytes[i*n : (i+1)*n], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownMember, member)
+}
+
+// members returns the members listed in the headers of the key.
+func members(key *pem.Block) []string {
+	m, ok := key.Headers[membersHeader]
+	if !ok || len(m) == 0 {
+		return nil
+	}
+	return strings.Split(m, memberSeparator)
+}
+
+// validateMembers ensures the members have unique names that can be stored in a key header.
+func validateMembers(members []string) error {
+	if len(members) == 0 {
+		return errors.New("group must have at least one member")
+	}
+	names := make(map[string]struct{}, len(members))
+	for _, m := range members {
+		switch {
+		case len(strings.TrimSpace(m)) != len(m), len(m) == 0:
+			return errors.New("member names must not be blank or have surrounding spaces: " + strconv.Quote(m))
+		case strings.ContainsAny(m, memberSeparator+":\r\n"):
+			return errors.New("member names must not contain commas, colons, or newlines: " + strconv.Quote(m))
+		}
+		if _, ok := names[m]; ok {
+			return errors.New("duplicate member: " + m)
+		}
+		names[m] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package otp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// groupKey is a group key with a region of 3 bytes for each of alice, bob, and carol:
+// 123 for alice, 456 for bob, and 789 for carol.
+const groupKey = `-----BEGIN OTP-----
+Members: alice,bob,carol
+
+AQIDBAUGBwgJ
+-----END OTP-----
+`
+
+func TestGenerateGroupKey(t *testing.T) {
+	generateGroupKeyTests := []struct {
+		members      []string
+		regionLength int
+		want         string
+		wantOk       bool
+	}{
+		{
+			members:      []string{"alice", "bob", "carol"},
+			regionLength: 3,
+			want:         groupKey,
+			wantOk:       true,
+		},
+		{ // no members
+			regionLength: 3,
+		},
+		{
+			members: []string{"alice", "bob"},
+		},
+		{
+			members:      []string{"alice", "alice"},
+			regionLength: 3,
+		},
+		{
+			members:      []string{"alice", "bob,carol"},
+			regionLength: 3,
+		},
+		{
+			members:      []string{"alice", " bob"},
+			regionLength: 3,
+		},
+		{
+			members:      []string{"alice", ""},
+			regionLength: 3,
+		},
+		{
+			members:      []string{"alice", "bob"},
+			regionLength: MaxKeyLength,
+		},
+	}
+	for i, test := range generateGroupKeyTests {
+		opts := KeyOptions{
+			Rand: strings.NewReader(string([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9})),
+		}
+		got, err := opts.GenerateGroupKey(test.members, test.regionLength)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != string(got):
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, string(got))
+		}
+	}
+}
+
+func TestEncryptGroup(t *testing.T) {
+	encryptGroupTests := []struct {
+		message string
+		key     string
+		sender  string
+		offset  int
+		want    string
+		wantOk  bool
+	}{
+		{
+			message: "CA",
+			key:     groupKey,
+			sender:  "bob",
+			// CA ^ 45
+			want: `-----BEGIN OTP-----
+Offset: 0
+Sender: bob
+
+R0Q=
+-----END OTP-----
+`,
+			wantOk: true,
+		},
+		{
+			message: "T",
+			key:     groupKey,
+			sender:  "carol",
+			offset:  2,
+			// T ^ 9
+			want: `-----BEGIN OTP-----
+Offset: 2
+Sender: carol
+
+XQ==
+-----END OTP-----
+`,
+			wantOk: true,
+		},
+		{ // no key
+			message: "CA",
+			sender:  "bob",
+		},
+		{ // not a group key
+			message: "CA",
+			key: `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`,
+			sender: "bob",
+		},
+		{ // unknown sender
+			message: "CA",
+			key:     groupKey,
+			sender:  "dave",
+		},
+		{ // message longer than region
+			message: "CAT",
+			key:     groupKey,
+			sender:  "bob",
+			offset:  1,
+		},
+		{
+			message: "",
+			key:     groupKey,
+			sender:  "bob",
+			offset:  4,
+		},
+		{
+			message: "",
+			key:     groupKey,
+			sender:  "bob",
+			offset:  -1,
+		},
+	}
+	for i, test := range encryptGroupTests {
+		got, err := EncryptGroup(test.message, []byte(test.key), test.sender, test.offset)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != string(got):
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, string(got))
+		}
+	}
+}
+
+func TestDecryptGroup(t *testing.T) {
+	decryptGroupTests := []struct {
+		cipher string
+		want   string
+		wantOk bool
+	}{
+		{
+			cipher: `-----BEGIN OTP-----
+Offset: 0
+Sender: bob
+
+R0Q=
+-----END OTP-----
+`,
+			want:   "CA",
+			wantOk: true,
+		},
+		{
+			cipher: `-----BEGIN OTP-----
+Offset: 2
+Sender: carol
+
+XQ==
+-----END OTP-----
+`,
+			want:   "T",
+			wantOk: true,
+		},
+		{ // unknown sender
+			cipher: `-----BEGIN OTP-----
+Offset: 0
+Sender: dave
+
+R0Q=
+-----END OTP-----
+`,
+		},
+		{ // cipher longer than remaining region
+			cipher: `-----BEGIN OTP-----
+Offset: 2
+Sender: bob
+
+R0Q=
+-----END OTP-----
+`,
+		},
+		{
+			cipher: `-----BEGIN OTP-----
+Offset: two
+Sender: bob
+
+R0Q=
+-----END OTP-----
+`,
+		},
+		{
+			cipher: `-----BEGIN OTP-----
+Offset: 4
+Sender: bob
+
+R0Q=
+-----END OTP-----
+`,
+		},
+	}
+	for i, test := range decryptGroupTests {
+		got, err := Decrypt(test.cipher, []byte(groupKey))
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != string(got):
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, string(got))
+		}
+	}
+}
+
+func TestGroupMembers(t *testing.T) {
+	groupMembersTests := []struct {
+		key    string
+		want   []string
+		wantOk bool
+	}{
+		{
+			key:    groupKey,
+			want:   []string{"alice", "bob", "carol"},
+			wantOk: true,
+		},
+		{ // not a group key
+			key: `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`,
+		},
+		{ // no key
+		},
+	}
+	for i, test := range groupMembersTests {
+		got, err := GroupMembers([]byte(test.key))
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case !reflect.DeepEqual(test.want, got):
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package otp
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+const (
+	// labelHeader is the key header that describes the key.
+	labelHeader = "Label"
+	// partnerHeader is the key header that names the user the key is shared with.
+	partnerHeader = "Partner"
+)
+
+// KeyOptions describe keys to generate.
+type KeyOptions struct {
+	// Label describes the key.
+	Label string
+	// Partner is the name of the user the key is shared with.
+	Partner string
+	// Entropy is additional randomness that is mixed into the key, such as text typed by the user.
+	// It does not replace the random source.
+	Entropy []byte
+	// Rand is the source of random bytes for keys.
+	// Keys are created from crypto/rand if it is nil.
+	// Sources other than crypto/rand, such as that of NewInsecureReader, should only be used for tests and demonstrations.
+	Rand io.Reader
+}
+
+// GenerateKey creates an encoded key with the options that encodes a message of up to the specified number of characters.
+func (opts KeyOptions) GenerateKey(length int) ([]byte, error) {
+	return opts.generate(length, nil)
+}
+
+// GenerateGroupKey creates an encoded key with the options with a separate region of regionLength bytes for each member.
+// Members only encrypt messages with their own region, but can decrypt messages from any member.
+func (opts KeyOptions) GenerateGroupKey(members []string, regionLength int) ([]byte, error) {
+	if err := validateMembers(members); err != nil {
+		return nil, err
+	}
+	if regionLength <= 0 {
+		return nil, fmt.Errorf("region: %w", ErrKeyTooSmall)
+	}
+	if regionLength > MaxKeyLength/len(members) {
+		return nil, fmt.Errorf("group key: %w", ErrKeyTooLarge)
+	}
+	headers := map[string]string{
+		membersHeader: strings.Join(members, memberSeparator),
+	}
+	return opts.generate(len(members)*regionLength, headers)
+}
+
+// ReadKeyOptions reads the label and partner of the key from its headers.
+func ReadKeyOptions(key []byte) (KeyOptions, error) {
+	k, err := decodeBlock(key)
+	if err != nil {
+		return KeyOptions{}, fmt.Errorf("decoding key: %w", err)
+	}
+	clear(k.Bytes)
+	opts := KeyOptions{
+		Label:   k.Headers[labelHeader],
+		Partner: k.Headers[partnerHeader],
+	}
+	return opts, nil
+}
+
+// generate creates an encoded key of the length with the headers and the label and partner of the options.
+func (opts KeyOptions) generate(length int, headers map[string]string) ([]byte, error) {
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	for name, value := range map[string]string{
+		labelHeader:   opts.Label,
+		partnerHeader: opts.Partner,
+	} {
+		switch {
+		case len(value) == 0:
+			continue
+		case strings.ContainsAny(value, "\r\n"):
+			return nil, errors.New(strings.ToLower(name) + " must not contain newlines: " + strconv.Quote(value))
+		}
+		headers[name] = value
+	}
+	b, err := randomBytes(opts.Rand, length)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(b)
+	if len(opts.Entropy) != 0 {
+		mixEntropy(b, opts.Entropy)
+	}
+	return encodeBlock(b, headers)
+}
+
+// mixEntropy xors the bytes with a stream of SHA-256 hashes of the entropy and a counter.
+// Mixing in the stream does not make random bytes less random.
+func mixEntropy(b, entropy []byte) {
+	seed := sha256.Sum256(entropy)
+	block := make([]byte, len(seed)+8)
+	copy(block, seed[:])
+	for i := 0; i < len(b); i += sha256.Size {
+		binary.BigEndian.PutUint64(block[len(seed):], uint64(i/sha256.Size))
+		stream := sha256.Sum256(block)
+		for j := 0; j < sha256.Size && i+j < len(b); j++ {
+			b[i+j] ^= stream[j]
+		}
+	}
+}
+
+// NewInsecureReader creates a deterministic source of random bytes from the seed.
+// The same seed always produces the same bytes, so keys from it are NOT SECURE.
+// It allows tests and demonstrations to produce reproducible keys.
+func NewInsecureReader(seed []byte) io.Reader {
+	return rand.NewChaCha8(sha256.Sum256(seed))
+}
+
+// Key is a decoded key whose bytes can be destroyed when they are no longer needed.
+// Keys should be destroyed after use so they do not linger in memory.
+type Key struct {
+	block *pem.Block
+}
+
+// ParseKey decodes the only OTP block in the text as a key.
+// The text is not kept, so it can be cleared after the key is parsed.
+func ParseKey(text []byte) (*Key, error) {
+	blk, err := decodeBlock(text)
+	if err != nil {
+		return nil, fmt.Errorf("decoding key: %w", err)
+	}
+	return newKey(blk)
+}
+
+// ParseKeyStrict decodes the text as a key, requiring it to be a single PEM block without any surrounding text.
+// The text is not kept, so it can be cleared after the key is parsed.
+func ParseKeyStrict(text []byte) (*Key, error) {
+	blk, err := decodeStrict(text)
+	if err != nil {
+		return nil, fmt.Errorf("decoding key: %w", err)
+	}
+	return newKey(blk)
+}
+
+// newKey creates a key from the decoded block, which must not be protected.
+func newKey(blk *pem.Block) (*Key, error) {
+	if _, ok := blk.Headers[protectionHeader]; ok {
+		clear(blk.Bytes)
+		return nil, ErrProtectedKey
+	}
+	return &Key{block: blk}, nil
+}
+
+// MarshalText encodes the key as a key file.
+// The caller should clear the text when it is no longer needed.
+func (k *Key) MarshalText() ([]byte, error) {
+	if k.block == nil {
+		return nil, ErrDestroyedKey
+	}
+	return encodeBlock(k.block.Bytes, k.block.Headers)
+}
+
+// UnmarshalText decodes the only OTP block in the text as the key, destroying the key it replaces.
+// The text is not kept, so it can be cleared after the key is decoded.
+func (k *Key) UnmarshalText(text []byte) error {
+	parsed, err := ParseKey(text)
+	if err != nil {
+		return err
+	}
+	k.Destroy()
+	*k = *parsed
+	return nil
+}
+
+// Destroy overwrites the bytes of the key with zeros.
+// The key cannot be used after it is destroyed.
+func (k *Key) Destroy() {
+	if k.block != nil {
+		clear(k.block.Bytes)
+		k.block = nil
+	}
+}
+
+// Len is the number of bytes in the key, or zero if the key is destroyed.
+func (k *Key) Len() int {
+	if k.block == nil {
+		return 0
+	}
+	return len(k.block.Bytes)
+}
+
+// Equal reports whether the keys have the same bytes in constant time.
+// Destroyed keys are not equal to any key.
+func (k *Key) Equal(other *Key) bool {
+	if k.block == nil || other.block == nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(k.block.Bytes, other.block.Bytes) == 1
+}
+
+// Fingerprint identifies the key without revealing it.
+func (k *Key) Fingerprint() string {
+	if k.block == nil {
+		return ""
+	}
+	return fingerprint(k.block.Bytes)
+}
+
+// Encrypt encrypts the message with the key and options to produce the cipher text.
+// The message is not changed, so it can be cleared after it is encrypted.
+func (k *Key) Encrypt(opts Options, message []byte) ([]byte, error) {
+	if k.block == nil {
+		return nil, ErrDestroyedKey
+	}
+	m, headers, err := opts.payload(message)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(m)
+	p, err := pad(&pem.Block{Headers: headers}, k.block)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(m) > len(p):
+		return nil, &LengthError{Err: ErrMessageTooLong, Length: len(m), Capacity: len(p)}
+	}
+	if !opts.wholeKey {
+		p = p[:len(m)] // do not reveal the unused part of the key
+	}
+	c := xor(m, p)
+	e, err := encodeBlock(c, headers)
+	if err != nil {
+		return nil, fmt.Errorf("encoding encrypted message: %w", err)
+	}
+	return e, nil
+}
+
+// Capacity is the number of unused bytes of the key that can encrypt messages with the options.
+func (k *Key) Capacity(opts Options) (int, error) {
+	if k.block == nil {
+		return 0, ErrDestroyedKey
+	}
+	if opts.Offset < 0 {
+		return 0, fmt.Errorf("%w: %d is negative", ErrInvalidOffset, opts.Offset)
+	}
+	p, err := pad(&pem.Block{Headers: opts.headers()}, k.block)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Decrypt decrypts the cipher text with the key to produce the message.
+// The caller should clear the message when it is no longer needed.
+func (k *Key) Decrypt(cipher []byte) ([]byte, error) {
+	if k.block == nil {
+		return nil, ErrDestroyedKey
+	}
+	c, err := decodeBlock(cipher)
+	if err != nil {
+		return nil, fmt.Errorf("decoding cipher text: %w", err)
+	}
+	return k.decrypt(c)
+}
+
+// DecryptStrict decrypts the cipher text with the key to produce the message, requiring the cipher text to be a single PEM block without any surrounding text.
+// The caller should clear the message when it is no longer needed.
+func (k *Key) DecryptStrict(cipher []byte) ([]byte, error) {
+	if k.block == nil {
+		return nil, ErrDestroyedKey
+	}
+	c, err := decodeStrict(cipher)
+	if err != nil {
+		return nil, fmt.Errorf("decoding cipher text: %w", err)
+	}
+	return k.decrypt(c)
+}
+
+// decrypt decrypts the decoded cipher text with the key.
+func (k *Key) decrypt(c *pem.Block) ([]byte, error) {
+	p, err := pad(c, k.block)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(c.Bytes) > len(p):
+		return nil, &LengthError{Err: ErrCipherTooLong, Length: len(c.Bytes), Capacity: len(p)}
+	}
+	m := xor(c.Bytes, p[:len(c.Bytes)])
+	if compression, ok := c.Headers[compressionHeader]; ok {
+		defer clear(m)
+		return decompress(m, compression)
+	}
+	return m, nil
+}
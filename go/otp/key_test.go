@@ -0,0 +1,285 @@
+package otp
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeyOptionsGenerateKey(t *testing.T) {
+	generateKeyTests := []struct {
+		opts   KeyOptions
+		want   string
+		wantOk bool
+	}{
+		{
+			opts: KeyOptions{
+				Label:   "lunch plans",
+				Partner: "bob",
+			},
+			want: `-----BEGIN OTP-----
+Label: lunch plans
+Partner: bob
+
+AQIDBAU=
+-----END OTP-----
+`,
+			wantOk: true,
+		},
+		{
+			opts: KeyOptions{
+				Label: "multi\nline",
+			},
+		},
+		{
+			opts: KeyOptions{
+				Partner: "bob\r",
+			},
+		},
+	}
+	for i, test := range generateKeyTests {
+		test.opts.Rand = strings.NewReader(string([]byte{1, 2, 3, 4, 5}))
+		got, err := test.opts.GenerateKey(5)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != string(got):
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, string(got))
+		}
+	}
+}
+
+func TestKeyOptionsEntropy(t *testing.T) {
+	random := bytes.Repeat([]byte{7}, 100)
+	generate := func(entropy string) []byte {
+		opts := KeyOptions{
+			Entropy: []byte(entropy),
+			Rand:    bytes.NewReader(random),
+		}
+		key, err := opts.GenerateKey(len(random))
+		if err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		k, err := decode(key)
+		if err != nil {
+			t.Fatalf("unwanted decode error: %v", err)
+		}
+		return k
+	}
+	a1 := generate("asdf")
+	a2 := generate("asdf")
+	b := generate("qwerty")
+	switch {
+	case !bytes.Equal(a1, a2):
+		t.Errorf("wanted the same keys when the random bytes and entropy are the same")
+	case bytes.Equal(a1, b):
+		t.Errorf("wanted different keys when the entropy is different")
+	case bytes.Equal(a1, random):
+		t.Errorf("wanted entropy to be mixed into key")
+	case bytes.Equal(a1[:32], a1[32:64]):
+		t.Errorf("wanted each block of the entropy stream to be different")
+	}
+}
+
+func TestReadKeyOptions(t *testing.T) {
+	key := `-----BEGIN OTP-----
+Label: lunch plans
+Members: alice,bob
+Partner: bob
+
+AQIDBAU=
+-----END OTP-----
+`
+	want := KeyOptions{
+		Label:   "lunch plans",
+		Partner: "bob",
+	}
+	got, err := ReadKeyOptions([]byte(key))
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case want.Label != got.Label, want.Partner != got.Partner:
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", want, got)
+	}
+	if _, err := ReadKeyOptions(nil); err == nil {
+		t.Errorf("wanted error for missing key")
+	}
+}
+
+func TestNewInsecureReader(t *testing.T) {
+	generate := func(seed string) string {
+		opts := KeyOptions{
+			Rand: NewInsecureReader([]byte(seed)),
+		}
+		key, err := opts.GenerateKey(50)
+		if err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		return string(key)
+	}
+	a1 := generate("training")
+	a2 := generate("training")
+	b := generate("other")
+	switch {
+	case a1 != a2:
+		t.Errorf("wanted the same keys for the same seed:\n%v\n%v", a1, a2)
+	case a1 == b:
+		t.Errorf("wanted different keys for different seeds")
+	}
+}
+
+func TestKey(t *testing.T) {
+	text := []byte(`-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`)
+	k, err := ParseKey(text)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	clear(text)
+	other, err := ParseKey([]byte(`-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`))
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if !k.Equal(other) {
+		t.Errorf("wanted keys to be equal after the text is cleared")
+	}
+	message := []byte("CAT")
+	cipher, err := k.Encrypt(Options{}, message)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if want, got := "CAT", string(message); want != got {
+		t.Errorf("wanted message to not be changed, got %q", got)
+	}
+	got, err := other.Decrypt(cipher)
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case string(got) != "CAT":
+		t.Errorf("wanted decrypted message to be CAT, got %q", got)
+	}
+	b := k.block.Bytes
+	k.Destroy()
+	switch {
+	case !bytes.Equal(make([]byte, 5), b):
+		t.Errorf("wanted key bytes to be zeroed, got %v", b)
+	case k.Len() != 0, k.Fingerprint() != "":
+		t.Errorf("wanted destroyed key to have no length or fingerprint")
+	case k.Equal(other):
+		t.Errorf("wanted destroyed key to not equal other key")
+	}
+	if _, err := k.Encrypt(Options{}, message); !errors.Is(err, ErrDestroyedKey) {
+		t.Errorf("wanted destroyed key error when encrypting, got %v", err)
+	}
+	if _, err := k.Decrypt(cipher); !errors.Is(err, ErrDestroyedKey) {
+		t.Errorf("wanted destroyed key error when decrypting, got %v", err)
+	}
+	k.Destroy() // should not panic
+}
+
+func TestKeyStrict(t *testing.T) {
+	key := "-----BEGIN OTP-----\nAQIDBAU=\n-----END OTP-----\n"
+	parseKeyStrictTests := []struct {
+		text    string
+		wantErr error
+	}{
+		{key, nil},
+		{"the key:\n" + key, ErrLeadingData},
+		{key + "thanks", ErrTrailingData},
+		{key + key, ErrTrailingData},
+		{"", ErrNoPEM},
+	}
+	for i, test := range parseKeyStrictTests {
+		k, err := ParseKeyStrict([]byte(test.text))
+		switch {
+		case test.wantErr != nil:
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("test %v: wanted error %v, got %v", i, test.wantErr, err)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case k.Len() != 5:
+			t.Errorf("test %v: wanted 5 byte key, got %v", i, k.Len())
+		}
+	}
+	k, err := ParseKeyStrict([]byte(key))
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	cipher, err := k.Encrypt(Options{}, []byte("CAT"))
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	decryptStrictTests := []struct {
+		cipher  string
+		wantErr error
+	}{
+		{string(cipher), nil},
+		{string(cipher) + "sent from my phone", ErrTrailingData},
+		{"", ErrNoPEM},
+	}
+	for i, test := range decryptStrictTests {
+		got, err := k.DecryptStrict([]byte(test.cipher))
+		switch {
+		case test.wantErr != nil:
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("test %v: wanted error %v, got %v", i, test.wantErr, err)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case string(got) != "CAT":
+			t.Errorf("test %v: wanted decrypted message to be CAT, got %q", i, got)
+		}
+	}
+	k.Destroy()
+	if _, err := k.DecryptStrict(cipher); !errors.Is(err, ErrDestroyedKey) {
+		t.Errorf("wanted destroyed key error when decrypting, got %v", err)
+	}
+}
+
+func TestKeyText(t *testing.T) {
+	text := `-----BEGIN OTP-----
+Label: lunch plans
+
+AQIDBAU=
+-----END OTP-----
+`
+	var k Key
+	if err := k.UnmarshalText([]byte(text)); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	got, err := k.MarshalText()
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case text != string(got):
+		t.Errorf("wanted %q, got %q", text, got)
+	}
+	b := k.block.Bytes
+	if err := k.UnmarshalText([]byte("-----BEGIN OTP-----\nBgc=\n-----END OTP-----\n")); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	switch {
+	case !bytes.Equal(make([]byte, 5), b):
+		t.Errorf("wanted replaced key bytes to be zeroed, got %v", b)
+	case k.Len() != 2:
+		t.Errorf("wanted replaced key to have 2 bytes, got %v", k.Len())
+	}
+	if err := k.UnmarshalText([]byte("no key")); !errors.Is(err, ErrNoPEM) {
+		t.Errorf("wanted no PEM error, got %v", err)
+	}
+	k.Destroy()
+	if _, err := k.MarshalText(); !errors.Is(err, ErrDestroyedKey) {
+		t.Errorf("wanted destroyed key error, got %v", err)
+	}
+}
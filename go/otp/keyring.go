@@ -0,0 +1,352 @@
+package otp
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// createdHeader is the keyring header that records when the key was added.
+	createdHeader = "Created"
+	// usedHeader is the keyring header that lists the used ranges of the key.
+	usedHeader = "Used"
+	// rangeSeparator separates used ranges in the used header.
+	rangeSeparator = ","
+)
+
+// Keyring holds many keys and records which parts of them have been used.
+// It is encoded as a bundle of the OTP blocks of its keys, so each key in it can still be used as a key.
+type Keyring struct {
+	Entries []KeyringEntry
+}
+
+// KeyringEntry is a key in a keyring.
+type KeyringEntry struct {
+	// ID is the fingerprint of the key.
+	ID string
+	// Label describes the key.
+	Label string
+	// Partner is the name of the user the key is shared with.
+	Partner string
+	// Created is when the key was added to the keyring.
+	Created time.Time
+	// Used are the parts of the key that have encrypted messages, in order.
+	// For group keys, the ranges are offsets in the region of the keyring's owner.
+	Used []Range
+	// key is the decoded key.
+	key *pem.Block
+}
+
+// Range is a part of a key, from the Start index up to the End index.
+type Range struct {
+	Start, End int
+}
+
+// ParseKeyring reads the keyring from text with the OTP blocks of its keys.
+// Blocks of keys that were not exported from a keyring, such as key files, are added as unused keys.
+// The text is not kept, so it can be cleared after the keyring is parsed.
+func ParseKeyring(text []byte) (*Keyring, error) {
+	var k Keyring
+	if err := k.UnmarshalText(text); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// MarshalText encodes the keys in the keyring as a bundle of OTP blocks.
+func (k Keyring) MarshalText() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, e := range k.Entries {
+		headers := e.headers()
+		if !e.Created.IsZero() {
+			headers[createdHeader] = e.Created.UTC().Format(time.RFC3339)
+		}
+		if len(e.Used) != 0 {
+			headers[usedHeader] = formatRanges(e.Used)
+		}
+		b, err := encodeBlock(e.key.Bytes, headers)
+		if err != nil {
+			return nil, fmt.Errorf("encoding %v: %w", e.ID, err)
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalText decodes the OTP blocks in the text into the keyring, replacing its keys.
+// The keyring is not changed if any of the blocks cannot be read, and the bytes of the blocks are zeroed.
+func (k *Keyring) UnmarshalText(text []byte) (err error) {
+	blocks := findBlocks(text)
+	if len(blocks) == 0 {
+		return ErrNoPEM
+	}
+	defer func() {
+		if err != nil {
+			for _, blk := range blocks {
+				clear(blk.Bytes)
+			}
+		}
+	}()
+	var r Keyring
+	for i, blk := range blocks {
+		e, err := newKeyringEntry(blk)
+		if err != nil {
+			return fmt.Errorf("reading key %d: %w", i, err)
+		}
+		if c, ok := blk.Headers[createdHeader]; ok {
+			created, err := time.Parse(time.RFC3339, c)
+			if err != nil {
+				return fmt.Errorf("reading created time of key %d: %w", i, err)
+			}
+			e.Created = created
+		}
+		used, err := parseRanges(blk.Headers[usedHeader])
+		if err != nil {
+			return fmt.Errorf("reading used parts of key %d: %w", i, err)
+		}
+		if n := len(used); n != 0 && used[n-1].End > e.Len() {
+			return fmt.Errorf("reading used parts of key %d: %w: %v is past the end of the key", i, ErrInvalidOffset, formatRanges(used[n-1:]))
+		}
+		e.Used = used
+		if _, err := r.find(e.ID); err == nil {
+			return fmt.Errorf("%w: %v", ErrDuplicateKey, e.ID)
+		}
+		r.Entries = append(r.Entries, *e)
+	}
+	*k = r
+	return nil
+}
+
+// Add puts the key in the keyring and returns its id.
+// The label and partner are read from the key's headers.
+// The text is not kept, so it can be cleared after the key is added.
+func (k *Keyring) Add(key []byte, created time.Time) (string, error) {
+	blk, err := decodeBlock(key)
+	if err != nil {
+		return "", fmt.Errorf("decoding key: %w", err)
+	}
+	return k.add(blk, created)
+}
+
+// AddKey puts a copy of the key in the keyring and returns its id, so the key can be destroyed without changing the keyring.
+// The label and partner are read from the key's headers.
+func (k *Keyring) AddKey(key *Key, created time.Time) (string, error) {
+	if key.block == nil {
+		return "", ErrDestroyedKey
+	}
+	blk := pem.Block{
+		Type:    key.block.Type,
+		Headers: maps.Clone(key.block.Headers),
+		Bytes:   bytes.Clone(key.block.Bytes),
+	}
+	return k.add(&blk, created)
+}
+
+// add puts the decoded key in the keyring and returns its id.
+func (k *Keyring) add(key *pem.Block, created time.Time) (string, error) {
+	e, err := newKeyringEntry(key)
+	if err != nil {
+		return "", err
+	}
+	if _, err := k.find(e.ID); err == nil {
+		return "", fmt.Errorf("%w: %v", ErrDuplicateKey, e.ID)
+	}
+	e.Created = created
+	k.Entries = append(k.Entries, *e)
+	return e.ID, nil
+}
+
+// Destroy overwrites the bytes of the keys in the keyring with zeros and removes them.
+func (k *Keyring) Destroy() {
+	for _, e := range k.Entries {
+		clear(e.key.Bytes)
+	}
+	k.Entries = nil
+}
+
+// Select returns the entry of the key with the id.
+func (k Keyring) Select(id string) (*KeyringEntry, error) {
+	i, err := k.find(id)
+	if err != nil {
+		return nil, err
+	}
+	e := k.Entries[i]
+	e.Used = slices.Clone(e.Used)
+	return &e, nil
+}
+
+// Remove deletes the key with the id from the keyring, zeroing its bytes.
+func (k *Keyring) Remove(id string) error {
+	i, err := k.find(id)
+	if err != nil {
+		return err
+	}
+	clear(k.Entries[i].key.Bytes)
+	k.Entries = slices.Delete(k.Entries, i, i+1)
+	return nil
+}
+
+// Consume records that length bytes of the key with the id were used, starting at the offset.
+// The bytes must be inside the key, or inside a region of a group key.
+// An error is returned if any of the bytes were already used so that no part of a key is used twice.
+func (k *Keyring) Consume(id string, offset, length int) error {
+	i, err := k.find(id)
+	if err != nil {
+		return err
+	}
+	e := &k.Entries[i]
+	if n := e.Len(); offset < 0 || length <= 0 || offset > n || length > n-offset {
+		return fmt.Errorf("%w: %d bytes at %d of %d", ErrInvalidOffset, length, offset, n)
+	}
+	used := Range{Start: offset, End: offset + length}
+	for _, r := range e.Used {
+		if used.Start < r.End && r.Start < used.End {
+			return fmt.Errorf("%w: %v", ErrKeyReused, formatRanges([]Range{r}))
+		}
+	}
+	e.Used = mergeRanges(append(e.Used, used))
+	return nil
+}
+
+// Export encodes the key with the id as a key file.
+// The used ranges of the key are kept in the key file, so they are not used again after it is imported.
+func (k Keyring) Export(id string) ([]byte, error) {
+	e, err := k.Select(id)
+	if err != nil {
+		return nil, err
+	}
+	headers := e.headers()
+	if len(e.Used) != 0 {
+		headers[usedHeader] = formatRanges(e.Used)
+	}
+	return encodeBlock(e.key.Bytes, headers)
+}
+
+// Len is the number of bytes of the key that the used ranges can be in.
+// For group keys, this is the length of a region.
+func (e KeyringEntry) Len() int {
+	n := len(e.key.Bytes)
+	if m := len(members(e.key)); m != 0 {
+		n /= m
+	}
+	return n
+}
+
+// Next is the first offset of the key after all of its used ranges.
+// Messages should be encrypted starting at this offset.
+func (e KeyringEntry) Next() int {
+	if len(e.Used) == 0 {
+		return 0
+	}
+	return e.Used[len(e.Used)-1].End
+}
+
+// Key encodes the key of the entry as a key file.
+func (e KeyringEntry) Key() ([]byte, error) {
+	return encodeBlock(e.key.Bytes, e.headers())
+}
+
+// find returns the index of the entry with the id.
+func (k Keyring) find(id string) (int, error) {
+	for i, e := range k.Entries {
+		if e.ID == id {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %v", ErrKeyNotFound, id)
+}
+
+// headers creates the key file headers of the entry, which exclude the keyring headers.
+func (e KeyringEntry) headers() map[string]string {
+	headers := make(map[string]string, len(e.key.Headers))
+	for name, value := range e.key.Headers {
+		switch name {
+		case createdHeader, usedHeader:
+		default:
+			headers[name] = value
+		}
+	}
+	if len(e.Label) != 0 {
+		headers[labelHeader] = e.Label
+	}
+	if len(e.Partner) != 0 {
+		headers[partnerHeader] = e.Partner
+	}
+	return headers
+}
+
+// newKeyringEntry creates an entry for the key, reading its label and partner from its headers.
+func newKeyringEntry(key *pem.Block) (*KeyringEntry, error) {
+	switch _, protected := key.Headers[protectionHeader]; {
+	case protected:
+		return nil, ErrProtectedKey
+	case len(key.Bytes) == 0:
+		return nil, ErrKeyTooSmall
+	}
+	e := KeyringEntry{
+		ID:      fingerprint(key.Bytes),
+		Label:   key.Headers[labelHeader],
+		Partner: key.Headers[partnerHeader],
+		key:     key,
+	}
+	return &e, nil
+}
+
+// formatRanges encodes the ranges for the used header, such as "0-10,20-25".
+func formatRanges(ranges []Range) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = strconv.Itoa(r.Start) + "-" + strconv.Itoa(r.End)
+	}
+	return strings.Join(parts, rangeSeparator)
+}
+
+// parseRanges decodes the used header, merging overlapping ranges.
+func parseRanges(s string) ([]Range, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	parts := strings.Split(s, rangeSeparator)
+	ranges := make([]Range, len(parts))
+	for i, p := range parts {
+		start, end, ok := strings.Cut(p, "-")
+		if !ok {
+			return nil, fmt.Errorf("range missing dash: %q", p)
+		}
+		a, err := strconv.Atoi(start)
+		if err != nil {
+			return nil, fmt.Errorf("parsing range start: %w", err)
+		}
+		b, err := strconv.Atoi(end)
+		if err != nil {
+			return nil, fmt.Errorf("parsing range end: %w", err)
+		}
+		if a < 0 || b <= a {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidOffset, p)
+		}
+		ranges[i] = Range{Start: a, End: b}
+	}
+	return mergeRanges(ranges), nil
+}
+
+// mergeRanges sorts the ranges and combines those that overlap or touch.
+func mergeRanges(ranges []Range) []Range {
+	slices.SortFunc(ranges, func(a, b Range) int {
+		return a.Start - b.Start
+	})
+	var merged []Range
+	for _, r := range ranges {
+		if n := len(merged); n != 0 && r.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
@@ -0,0 +1,290 @@
+package otp
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const keyringText = `-----BEGIN OTP-----
+Created: 2026-01-02T03:04:05Z
+Label: lunch plans
+Partner: bob
+Used: 0-2
+
+AQIDBAU=
+-----END OTP-----
+-----BEGIN OTP-----
+Created: 2026-02-03T04:05:06Z
+
+BgcICQo=
+-----END OTP-----
+`
+
+func TestKeyringText(t *testing.T) {
+	k, err := ParseKeyring([]byte(keyringText))
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if want, got := 2, len(k.Entries); want != got {
+		t.Fatalf("wanted %v keys, got %v", want, got)
+	}
+	e := k.Entries[0]
+	switch {
+	case e.Label != "lunch plans", e.Partner != "bob":
+		t.Errorf("wanted label and partner to be read, got %+v", e)
+	case !e.Created.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)):
+		t.Errorf("wanted created time to be read, got %v", e.Created)
+	case !reflect.DeepEqual([]Range{{Start: 0, End: 2}}, e.Used):
+		t.Errorf("wanted used ranges to be read, got %v", e.Used)
+	case e.Next() != 2:
+		t.Errorf("wanted next offset to be 2, got %v", e.Next())
+	}
+	got, err := k.MarshalText()
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case keyringText != string(got):
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", keyringText, string(got))
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	parseKeyringTests := []struct {
+		text   string
+		want   int
+		wantOk bool
+	}{
+		{ // key files
+			text: `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+-----BEGIN OTP-----
+BgcICQo=
+-----END OTP-----
+`,
+			want:   2,
+			wantOk: true,
+		},
+		{ // duplicate key
+			text: `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`,
+		},
+		{ // bad used range
+			text: `-----BEGIN OTP-----
+Used: 3-1
+
+AQIDBAU=
+-----END OTP-----
+`,
+		},
+		{ // used range past the end of the key
+			text: `-----BEGIN OTP-----
+Used: 3-6
+
+AQIDBAU=
+-----END OTP-----
+`,
+		},
+		{ // used range past the end of a region of a group key
+			text: `-----BEGIN OTP-----
+Members: alice,bob
+Used: 0-3
+
+AQIDBA==
+-----END OTP-----
+`,
+		},
+		{ // bad created time
+			text: `-----BEGIN OTP-----
+Created: yesterday
+
+AQIDBAU=
+-----END OTP-----
+`,
+		},
+		{},
+	}
+	for i, test := range parseKeyringTests {
+		k, err := ParseKeyring([]byte(test.text))
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != len(k.Entries):
+			t.Errorf("test %v: wanted %v keys, got %v", i, test.want, len(k.Entries))
+		}
+	}
+}
+
+func TestKeyringAddSelectRemove(t *testing.T) {
+	key := `-----BEGIN OTP-----
+Label: lunch plans
+
+AQIDBAU=
+-----END OTP-----
+`
+	var k Keyring
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	id, err := k.Add([]byte(key), created)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if _, err := k.Add([]byte(key), created); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("wanted duplicate key error, got %v", err)
+	}
+	e, err := k.Select(id)
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case e.Label != "lunch plans", !e.Created.Equal(created):
+		t.Errorf("wanted added key, got %+v", e)
+	}
+	b := k.Entries[0].key.Bytes
+	if err := k.Remove(id); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+	if !reflect.DeepEqual(make([]byte, 5), b) {
+		t.Errorf("wanted removed key bytes to be zeroed, got %v", b)
+	}
+	if _, err := k.Select(id); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("wanted key not found error, got %v", err)
+	}
+}
+
+func TestKeyringConsume(t *testing.T) {
+	consumeTests := []struct {
+		offset int
+		length int
+		want   []Range
+		wantOk bool
+	}{
+		{
+			offset: 2,
+			length: 1,
+			want:   []Range{{Start: 0, End: 3}},
+			wantOk: true,
+		},
+		{
+			offset: 4,
+			length: 1,
+			want:   []Range{{Start: 0, End: 2}, {Start: 4, End: 5}},
+			wantOk: true,
+		},
+		{ // reused
+			offset: 1,
+			length: 2,
+		},
+		{
+			offset: -1,
+			length: 2,
+		},
+		{
+			offset: 2,
+		},
+		{ // past the end of the key
+			offset: 4,
+			length: 2,
+		},
+		{ // after the end of the key
+			offset: 6,
+			length: 1,
+		},
+		{ // overflows
+			offset: 3,
+			length: math.MaxInt,
+		},
+	}
+	for i, test := range consumeTests {
+		k, err := ParseKeyring([]byte(keyringText))
+		if err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		id := k.Entries[0].ID
+		err = k.Consume(id, test.offset, test.length)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case !reflect.DeepEqual(test.want, k.Entries[0].Used):
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, k.Entries[0].Used)
+		}
+	}
+}
+
+func TestKeyringExport(t *testing.T) {
+	k, err := ParseKeyring([]byte(keyringText))
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	want := `-----BEGIN OTP-----
+Label: lunch plans
+Partner: bob
+Used: 0-2
+
+AQIDBAU=
+-----END OTP-----
+`
+	got, err := k.Export(k.Entries[0].ID)
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case want != string(got):
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", want, string(got))
+	}
+}
+
+func TestKeyringAddKeyDestroy(t *testing.T) {
+	key, err := ParseKey([]byte(`-----BEGIN OTP-----
+Label: lunch plans
+
+AQIDBAU=
+-----END OTP-----
+`))
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var k Keyring
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	id, err := k.AddKey(key, created)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if _, err := k.AddKey(key, created); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("wanted duplicate key error, got %v", err)
+	}
+	key.Destroy()
+	e, err := k.Select(id)
+	switch {
+	case err != nil:
+		t.Fatalf("unwanted error: %v", err)
+	case e.Label != "lunch plans", id != fingerprint([]byte{1, 2, 3, 4, 5}):
+		t.Errorf("wanted added key, got %+v", e)
+	case !reflect.DeepEqual([]byte{1, 2, 3, 4, 5}, e.key.Bytes):
+		t.Errorf("wanted keyring to keep its copy after the key is destroyed, got %v", e.key.Bytes)
+	}
+	if _, err := k.AddKey(key, created); !errors.Is(err, ErrDestroyedKey) {
+		t.Errorf("wanted destroyed key error, got %v", err)
+	}
+	b := e.key.Bytes
+	k.Destroy()
+	switch {
+	case !reflect.DeepEqual(make([]byte, 5), b):
+		t.Errorf("wanted key bytes to be zeroed, got %v", b)
+	case len(k.Entries) != 0:
+		t.Errorf("wanted destroyed keyring to have no keys, got %v", len(k.Entries))
+	}
+}
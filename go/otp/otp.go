@@ -2,68 +2,217 @@
 package otp
 
 import (
+	"bytes"
 	"crypto/rand"
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
 )
 
 // MaxKeyLength is the maximum size of keys.
 const MaxKeyLength = 50000
 
-// KeyGenerator is reader that is used to generate keys.
-var KeyGenerator = rand.Reader
+// Options change how messages are encrypted.
+type Options struct {
+	// Sender is the group member whose region of a group key encrypts the message.
+	// Messages encrypted with keys that are not group keys have no sender.
+	Sender string
+	// Offset is the index of the first unused byte of the key, or of the sender's region of a group key.
+	Offset int
+	// Compress compresses the message before it is encrypted when doing so uses fewer bytes of the key.
+	Compress bool
+	// wholeKey encrypts the message with all of the key, making the cipher text as long as the key.
+	wholeKey bool
+	// container marks the message as a serialized Container.
+	container bool
+}
 
-// Encrypt encrypts the message using the key to produce the cipher text.
-func Encrypt(message, key string) ([]byte, error) {
-	k, err := decode([]byte(key))
-	switch {
-	case err != nil:
-		return nil, errors.New("decoding key: " + err.Error())
-	case len(message) > len(k):
-		return nil, errors.New("message must not be longer than key")
+// Encrypt encrypts the message using the text of the key to produce the cipher text.
+// The cipher text is as long as the key, so the key must not be used again.
+// The text of the key is not changed, so it can be cleared after the message is encrypted.
+func Encrypt(message string, key []byte) ([]byte, error) {
+	opts := Options{
+		wholeKey: true,
+	}
+	return opts.Encrypt(message, key)
+}
+
+// Encrypt encrypts the message using the key and options to produce the cipher text.
+// Only the part of the key after the offset that is needed for the message is used.
+// The cipher text has headers that describe the options that are needed to decrypt it.
+func (opts Options) Encrypt(message string, key []byte) ([]byte, error) {
+	k, err := ParseKey(key)
+	if err != nil {
+		return nil, err
 	}
+	defer k.Destroy()
 	m := []byte(message)
-	c := xor(m, k)
-	e, err := encode(c)
+	defer clear(m)
+	return k.Encrypt(opts, m)
+}
+
+// PadLength is the number of key bytes that are used to encrypt the message with the options.
+func (opts Options) PadLength(message string) (int, error) {
+	m := []byte(message)
+	defer clear(m)
+	p, _, err := opts.payload(m)
 	if err != nil {
-		return nil, errors.New("encoding encrypted message: " + err.Error())
+		return 0, err
 	}
-	return e, nil
+	defer clear(p)
+	return len(p), nil
 }
 
-// Decrypt decrypts the cipher text using the key to produce the message.
-func Decrypt(cipher, key string) ([]byte, error) {
-	c, err := decode([]byte(cipher))
+// Capacity is the number of unused key bytes that can encrypt messages with the options.
+// For group keys, this is the number of bytes after the offset in the region of the sender.
+func (opts Options) Capacity(key []byte) (int, error) {
+	k, err := ParseKey(key)
 	if err != nil {
-		return nil, errors.New("decoding cipher text: " + err.Error())
+		return 0, err
 	}
-	k, err := decode([]byte(key))
-	switch {
-	case err != nil:
-		return nil, errors.New("decoding key: " + err.Error())
-	case len(c) > len(k):
-		return nil, errors.New("cipher text must not be longer than key")
+	defer k.Destroy()
+	return k.Capacity(opts)
+}
+
+// ParseOptions reads the options that were used to encrypt the cipher text from its headers.
+func ParseOptions(cipher string) (Options, error) {
+	c, err := decodeBlock([]byte(cipher))
+	if err != nil {
+		return Options{}, fmt.Errorf("decoding cipher text: %w", err)
+	}
+	_, compressed := c.Headers[compressionHeader]
+	_, container := c.Headers[containerHeader]
+	opts := Options{
+		Sender:    c.Headers[senderHeader],
+		Compress:  compressed,
+		container: container,
+	}
+	o, ok := c.Headers[offsetHeader]
+	if !ok {
+		opts.wholeKey = true
+		return opts, nil
+	}
+	offset, err := strconv.Atoi(o)
+	if err != nil {
+		return Options{}, fmt.Errorf("parsing cipher offset: %w", err)
+	}
+	opts.Offset = offset
+	return opts, nil
+}
+
+// CipherRange is the part of the key, or of the sender's region of a group key, that encrypted the cipher text.
+// Cipher text that was encrypted with the whole key starts at zero.
+func CipherRange(cipher string) (Range, error) {
+	opts, err := ParseOptions(cipher)
+	if err != nil {
+		return Range{}, err
+	}
+	c, err := decodeBlock([]byte(cipher))
+	if err != nil {
+		return Range{}, fmt.Errorf("decoding cipher text: %w", err)
+	}
+	r := Range{
+		Start: opts.Offset,
+		End:   opts.Offset + len(c.Bytes),
 	}
-	m := xor(c, k)
-	return m, nil
+	return r, nil
+}
+
+// payload creates the bytes to encrypt for the message and the cipher headers that describe them.
+// The bytes are a copy of the message, or of the compressed message, that should be cleared after they are encrypted.
+func (opts Options) payload(message []byte) ([]byte, map[string]string, error) {
+	if opts.Offset < 0 {
+		return nil, nil, fmt.Errorf("%w: %d is negative", ErrInvalidOffset, opts.Offset)
+	}
+	m := bytes.Clone(message)
+	headers := opts.headers()
+	if opts.Compress {
+		c, err := compress(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(c) >= len(m) {
+			clear(c)
+		} else {
+			clear(m)
+			m = c
+			headers[compressionHeader] = deflateEnglish
+		}
+	}
+	return m, headers, nil
+}
+
+// headers creates the cipher headers that describe the options.
+func (opts Options) headers() map[string]string {
+	headers := make(map[string]string)
+	if len(opts.Sender) != 0 {
+		headers[senderHeader] = opts.Sender
+	}
+	if !opts.wholeKey {
+		headers[offsetHeader] = strconv.Itoa(opts.Offset)
+	}
+	if opts.container {
+		headers[containerHeader] = strconv.Itoa(containerVersion)
+	}
+	return headers
+}
+
+// Decrypt decrypts the cipher text using the text of the key to produce the message.
+// Cipher text from a group key is decrypted with the region of the member who sent it.
+// Compressed messages are decompressed.
+func Decrypt(cipher string, key []byte) ([]byte, error) {
+	k, err := ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Destroy()
+	return k.Decrypt([]byte(cipher))
 }
 
 // GenerateKey creates an encoded key that that encodes a message of up to the specified number of characters.
 func GenerateKey(length int) ([]byte, error) {
+	return KeyOptions{}.GenerateKey(length)
+}
+
+// randomBytes reads the specified number of bytes from the reader.
+// Bytes are read from crypto/rand if the reader is nil.
+// Readers that return fewer bytes than requested are read again until the key is full.
+func randomBytes(r io.Reader, length int) ([]byte, error) {
 	switch {
 	case length <= 0:
-		return nil, errors.New("key must have positive number of characters")
+		return nil, ErrKeyTooSmall
 	case length > MaxKeyLength:
-		return nil, errors.New("key length too large")
+		return nil, ErrKeyTooLarge
+	}
+	if r == nil {
+		r = rand.Reader
 	}
 	b := make([]byte, length)
-	n, err := KeyGenerator.Read(b)
-	switch {
-	case err != nil:
-		return nil, errors.New("generating key: " + err.Error())
-	case n != length:
-		return nil, errors.New("could not create key of desired length")
+	if _, err := io.ReadFull(r, b); err != nil {
+		clear(b)
+		return nil, fmt.Errorf("generating key: %w", err)
 	}
-	return encode(b)
+	return b, nil
+}
+
+// Fingerprint identifies the key without revealing it.
+// Users can compare fingerprints to confirm that they have copies of the same key.
+func Fingerprint(key []byte) (string, error) {
+	k, err := ParseKey(key)
+	if err != nil {
+		return "", err
+	}
+	defer k.Destroy()
+	return k.Fingerprint(), nil
+}
+
+// fingerprint identifies the decoded key by the start of its hash.
+func fingerprint(k []byte) string {
+	sum := sha256.Sum256(k)
+	h := hex.EncodeToString(sum[:8])
+	return h[:4] + ":" + h[4:8] + ":" + h[8:12] + ":" + h[12:]
 }
 
 // Xor performs the exclusive-or operation on the two arrays, returning an array the size of the largest array.
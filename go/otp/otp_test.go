@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestEncrypt(t *testing.T) {
@@ -55,7 +56,7 @@ AQIDBAU=
 		},
 	}
 	for i, test := range encryptTests {
-		got, err := Encrypt(test.message, test.key)
+		got, err := Encrypt(test.message, []byte(test.key))
 		switch {
 		case !test.wantOk:
 			if err == nil {
@@ -125,7 +126,7 @@ Q0FTVFJPUEhF
 		},
 	}
 	for i, test := range decryptTests {
-		got, err := Decrypt(test.cipher, test.key)
+		got, err := Decrypt(test.cipher, []byte(test.key))
 		switch {
 		case !test.wantOk:
 			if err == nil {
@@ -173,6 +174,15 @@ MTIz
 		{
 			keyLength: 1234567890,
 		},
+		{
+			keyLength:    5,
+			keyGenerator: iotest.OneByteReader(strings.NewReader(string([]byte{1, 2, 3, 4, 5}))),
+			want: `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`,
+			wantOk: true,
+		},
 		{
 			keyLength:    10,
 			keyGenerator: strings.NewReader("12345"),
@@ -183,8 +193,10 @@ MTIz
 		},
 	}
 	for i, test := range generateKeyTests {
-		KeyGenerator = test.keyGenerator
-		got, err := GenerateKey(test.keyLength)
+		opts := KeyOptions{
+			Rand: test.keyGenerator,
+		}
+		got, err := opts.GenerateKey(test.keyLength)
 		switch {
 		case !test.wantOk:
 			if err == nil {
@@ -198,6 +210,278 @@ MTIz
 	}
 }
 
+func TestCapacity(t *testing.T) {
+	capacityTests := []struct {
+		key    string
+		opts   Options
+		want   int
+		wantOk bool
+	}{
+		{
+			// 12345 :
+			key: `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`,
+			want:   5,
+			wantOk: true,
+		},
+		{
+			// 12345 :
+			key: `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`,
+			opts: Options{
+				Offset: 2,
+			},
+			want:   3,
+			wantOk: true,
+		},
+		{
+			key: groupKey,
+			opts: Options{
+				Sender: "bob",
+				Offset: 1,
+			},
+			want:   2,
+			wantOk: true,
+		},
+		{ // no key
+		},
+		{
+			key: groupKey,
+			opts: Options{
+				Sender: "dave",
+			},
+		},
+		{
+			key: groupKey,
+			opts: Options{
+				Sender: "bob",
+				Offset: -1,
+			},
+		},
+		{
+			key: groupKey,
+			opts: Options{
+				Sender: "bob",
+				Offset: 4,
+			},
+		},
+	}
+	for i, test := range capacityTests {
+		got, err := test.opts.Capacity([]byte(test.key))
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != got:
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, got)
+		}
+	}
+}
+
+func TestOptionsEncrypt(t *testing.T) {
+	// 12345 :
+	key := `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`
+	encryptTests := []struct {
+		message string
+		opts    Options
+		want    string
+		wantOk  bool
+	}{
+		{
+			message: "CAT",
+			// BCW :
+			want: `-----BEGIN OTP-----
+Offset: 0
+
+QkNX
+-----END OTP-----
+`,
+			wantOk: true,
+		},
+		{
+			message: "CA",
+			opts: Options{
+				Offset: 3,
+			},
+			// GD :
+			want: `-----BEGIN OTP-----
+Offset: 3
+
+R0Q=
+-----END OTP-----
+`,
+			wantOk: true,
+		},
+		{ // message longer than remaining key
+			message: "CAT",
+			opts: Options{
+				Offset: 3,
+			},
+		},
+		{
+			message: "CAT",
+			opts: Options{
+				Offset: -1,
+			},
+		},
+	}
+	for i, test := range encryptTests {
+		got, err := test.opts.Encrypt(test.message, []byte(key))
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != string(got):
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, string(got))
+		default:
+			m, err := Decrypt(string(got), []byte(key))
+			if err != nil || test.message != string(m) {
+				t.Errorf("test %v: wanted to decrypt %q, got %q (error: %v)", i, test.message, m, err)
+			}
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	parseOptionsTests := []struct {
+		cipher string
+		want   Options
+		wantOk bool
+	}{
+		{
+			cipher: `-----BEGIN OTP-----
+QkNXBAU=
+-----END OTP-----
+`,
+			want: Options{
+				wholeKey: true,
+			},
+			wantOk: true,
+		},
+		{
+			cipher: `-----BEGIN OTP-----
+Compression: deflate-english
+Offset: 7
+Sender: bob
+
+R0Q=
+-----END OTP-----
+`,
+			want: Options{
+				Sender:   "bob",
+				Offset:   7,
+				Compress: true,
+			},
+			wantOk: true,
+		},
+		{
+			cipher: `-----BEGIN OTP-----
+Offset: seven
+
+R0Q=
+-----END OTP-----
+`,
+		},
+		{ // no cipher
+		},
+	}
+	for i, test := range parseOptionsTests {
+		got, err := ParseOptions(test.cipher)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != got:
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, got)
+		}
+	}
+}
+
+func TestCipherRange(t *testing.T) {
+	cipherRangeTests := []struct {
+		cipher string
+		want   Range
+		wantOk bool
+	}{
+		{
+			cipher: `-----BEGIN OTP-----
+QkNXBAU=
+-----END OTP-----
+`,
+			want:   Range{Start: 0, End: 5},
+			wantOk: true,
+		},
+		{
+			cipher: `-----BEGIN OTP-----
+Offset: 7
+Sender: bob
+
+R0Q=
+-----END OTP-----
+`,
+			want:   Range{Start: 7, End: 9},
+			wantOk: true,
+		},
+		{
+			cipher: `-----BEGIN OTP-----
+Offset: seven
+
+R0Q=
+-----END OTP-----
+`,
+		},
+		{ // no cipher
+		},
+	}
+	for i, test := range cipherRangeTests {
+		got, err := CipherRange(test.cipher)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != got:
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, got)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	key := `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`
+	want := "74f8:1fe1:67d9:9b4c"
+	got, err := Fingerprint([]byte(key))
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case want != got:
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", want, got)
+	}
+	if _, err := Fingerprint(nil); err == nil {
+		t.Errorf("wanted error for missing key")
+	}
+}
+
 func TestXor(t *testing.T) {
 	xorTests := []struct {
 		a    []byte
@@ -238,5 +522,5 @@ func TestXor(t *testing.T) {
 type errorReader struct{}
 
 func (r *errorReader) Read(b []byte) (n int, err error) {
-	return len(b), errors.New("errorReader returns an error when read")
+	return 0, errors.New("errorReader returns an error when read")
 }
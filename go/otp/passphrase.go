@@ -0,0 +1,95 @@
+package otp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+const (
+	// PassphraseIterations is the default number of PBKDF2 iterations used to derive keys from passphrases.
+	PassphraseIterations = 600000
+	// saltLength is the number of bytes of random salt used to derive keys from passphrases.
+	saltLength = 16
+	// lockKeyLength is the length of derived AES-256 keys.
+	lockKeyLength = 32
+)
+
+// Lock encrypts and decrypts data with a key derived from a passphrase.
+// It protects keys at rest, not messages.
+type Lock struct {
+	aead cipher.AEAD
+}
+
+// NewSalt creates a random salt to derive a key from a passphrase.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generating salt: %w", err)
+	}
+	return salt, nil
+}
+
+// NewLock derives an AES-GCM key from the passphrase with PBKDF2-SHA256.
+// The same passphrase, salt, and iterations must be used to open data that the lock sealed.
+func NewLock(passphrase string, salt []byte, iterations int) (*Lock, error) {
+	switch {
+	case len(passphrase) == 0:
+		return nil, errors.New("passphrase required")
+	case len(salt) == 0:
+		return nil, errors.New("salt required")
+	case iterations <= 0:
+		return nil, errors.New("iterations must be positive")
+	}
+	k, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, lockKeyLength)
+	if err != nil {
+		return nil, fmt.Errorf("deriving key from passphrase: %w", err)
+	}
+	b, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(b)
+	if err != nil {
+		return nil, fmt.Errorf("creating gcm: %w", err)
+	}
+	return &Lock{aead: aead}, nil
+}
+
+// Seal encrypts and authenticates the data.
+// The random nonce is prepended to the sealed data.
+func (l Lock) Seal(data []byte) ([]byte, error) {
+	return l.seal(data, nil)
+}
+
+// Open decrypts data from Seal.
+// An error is returned if the data was sealed with a different passphrase or was modified.
+func (l Lock) Open(sealed []byte) ([]byte, error) {
+	return l.open(sealed, nil)
+}
+
+// seal encrypts and authenticates the data, and authenticates the additional data without encrypting it.
+func (l Lock) seal(data, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, l.aead.NonceSize(), l.aead.NonceSize()+len(data)+l.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return l.aead.Seal(nonce, nonce, data, additionalData), nil
+}
+
+// open decrypts data from seal, which must have been sealed with the same additional data.
+func (l Lock) open(sealed, additionalData []byte) ([]byte, error) {
+	n := l.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("sealed data too short")
+	}
+	data, err := l.aead.Open(nil, sealed[:n], sealed[n:], additionalData)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return data, nil
+}
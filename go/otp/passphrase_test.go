@@ -0,0 +1,86 @@
+package otp
+
+import (
+	"testing"
+)
+
+func TestNewLock(t *testing.T) {
+	newLockTests := []struct {
+		passphrase string
+		salt       []byte
+		iterations int
+		wantOk     bool
+	}{
+		{
+			passphrase: "correct horse battery staple",
+			salt:       []byte("salt"),
+			iterations: 1,
+			wantOk:     true,
+		},
+		{
+			salt:       []byte("salt"),
+			iterations: 1,
+		},
+		{
+			passphrase: "correct horse battery staple",
+			iterations: 1,
+		},
+		{
+			passphrase: "correct horse battery staple",
+			salt:       []byte("salt"),
+		},
+	}
+	for i, test := range newLockTests {
+		got, err := NewLock(test.passphrase, test.salt, test.iterations)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case got == nil:
+			t.Errorf("test %v: wanted lock", i)
+		}
+	}
+}
+
+func TestLockSealOpen(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatalf("unwanted salt error: %v", err)
+	}
+	l1, err := NewLock("passphrase-1", salt, 10)
+	if err != nil {
+		t.Fatalf("unwanted lock error: %v", err)
+	}
+	l2, err := NewLock("passphrase-2", salt, 10)
+	if err != nil {
+		t.Fatalf("unwanted lock error: %v", err)
+	}
+	want := "secret key"
+	sealed, err := l1.Seal([]byte(want))
+	switch {
+	case err != nil:
+		t.Fatalf("unwanted seal error: %v", err)
+	case string(sealed) == want:
+		t.Errorf("wanted sealed data to be encrypted")
+	}
+	got, err := l1.Open(sealed)
+	switch {
+	case err != nil:
+		t.Errorf("unwanted open error: %v", err)
+	case want != string(got):
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", want, string(got))
+	}
+	if _, err := l2.Open(sealed); err == nil {
+		t.Errorf("wanted error opening with wrong passphrase")
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := l1.Open(sealed); err == nil {
+		t.Errorf("wanted error opening modified data")
+	}
+	if _, err := l1.Open(sealed[:3]); err == nil {
+		t.Errorf("wanted error opening short data")
+	}
+}
@@ -3,32 +3,130 @@ package otp
 import (
 	"bytes"
 	"encoding/pem"
-	"errors"
+	"fmt"
 )
 
 // encode encodes the byte array with PEM encoding.
 func encode(b []byte) ([]byte, error) {
+	return encodeBlock(b, nil)
+}
+
+// encodeBlock encodes the byte array with PEM encoding, including the headers.
+func encodeBlock(b []byte, headers map[string]string) ([]byte, error) {
 	var buff bytes.Buffer
 	blk := pem.Block{
-		Type:  "OTP",
-		Bytes: b,
+		Type:    otpType,
+		Headers: headers,
+		Bytes:   b,
 	}
 	err := pem.Encode(&buff, &blk)
 	if err != nil {
-		return nil, errors.New("applying pem encoding: " + err.Error())
+		return nil, fmt.Errorf("applying pem encoding: %w", err)
 	}
 	return buff.Bytes(), nil
 }
 
-// decode decodes the byte array with PEM encoding.
+// otpType is the type of PEM blocks that hold keys and cipher text.
+const otpType = "OTP"
+
+// decode decodes the OTP block in the byte array with PEM encoding.
 func decode(b []byte) ([]byte, error) {
+	blk, err := decodeBlock(b)
+	if err != nil {
+		return nil, err
+	}
+	return blk.Bytes, nil
+}
+
+// decodeBlock decodes the only OTP block in the byte array with PEM encoding, keeping the headers.
+// Text around the block, other types of PEM blocks, carriage returns, and whitespace around lines are ignored.
+func decodeBlock(b []byte) (*pem.Block, error) {
+	blocks := findBlocks(b)
+	switch len(blocks) {
+	case 0:
+		return nil, ErrNoPEM
+	case 1:
+		return blocks[0], nil
+	default:
+		return nil, fmt.Errorf("%w: found %d", ErrMultipleBlocks, len(blocks))
+	}
+}
+
+// decodeStrict decodes the byte array with PEM encoding, requiring it to be a single OTP block without any surrounding text.
+func decodeStrict(b []byte) (*pem.Block, error) {
 	blk, rest := pem.Decode(b)
 	switch {
 	case blk == nil:
-		return nil, errors.New("no PEM data to decode")
+		return nil, ErrNoPEM
+	case blk.Type != otpType:
+		clear(blk.Bytes)
+		return nil, ErrNoPEM
+	case !bytes.HasPrefix(b, []byte("-----BEGIN ")):
+		clear(blk.Bytes)
+		return nil, ErrLeadingData
 	case len(rest) != 0:
-		return nil, errors.New("extra text after PEM data")
+		clear(blk.Bytes)
+		return nil, ErrTrailingData
 	default:
-		return blk.Bytes, nil
+		return blk, nil
+	}
+}
+
+// findBlocks decodes all of the OTP blocks in the byte array, in order.
+func findBlocks(b []byte) []*pem.Block {
+	var blocks []*pem.Block
+	normalized := normalizeLines(b)
+	defer clear(normalized)
+	rest := normalized
+	for {
+		blk, r := pem.Decode(rest)
+		if blk == nil {
+			return blocks
+		}
+		if blk.Type == otpType {
+			blocks = append(blocks, blk)
+		}
+		rest = r
+	}
+}
+
+// normalizeLines removes whitespace, including carriage returns, from the start and end of each line.
+func normalizeLines(b []byte) []byte {
+	lines := bytes.Split(b, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.TrimSpace(line)
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// Validate checks that the text has exactly one OTP block that can be used as a key or cipher.
+// Text around the block is allowed.
+func Validate(text []byte) error {
+	_, err := decodeBlock(text)
+	return err
+}
+
+// ValidateStrict checks that the text is a single OTP block without any surrounding text.
+func ValidateStrict(text []byte) error {
+	_, err := decodeStrict(text)
+	return err
+}
+
+// ValidateBundle checks that every OTP block in the text can be decoded, returning the number of blocks.
+// Text around and between the blocks is allowed.
+func ValidateBundle(text []byte) (int, error) {
+	blocks := findBlocks(text)
+	for _, blk := range blocks {
+		clear(blk.Bytes)
+	}
+	normalized := normalizeLines(text)
+	defer clear(normalized)
+	begins := bytes.Count(normalized, []byte("-----BEGIN "+otpType+"-----\n"))
+	switch {
+	case len(blocks) == 0 && begins == 0:
+		return 0, ErrNoPEM
+	case len(blocks) < begins:
+		return len(blocks), fmt.Errorf("%w: %d of %d blocks", ErrInvalidBlock, begins-len(blocks), begins)
 	}
+	return len(blocks), nil
 }
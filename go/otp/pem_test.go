@@ -1,6 +1,9 @@
 package otp
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestEncode(t *testing.T) {
 	b := []byte("HELLO")
@@ -31,20 +34,49 @@ SEVMTE8=
 			want:   "HELLO",
 			wantOk: true,
 		},
-		{
+		{ // text around block
+			b: `Here is the message:
+
+-----BEGIN OTP-----
+SEVMTE8=
+-----END OTP-----
+
+--
+sent from my phone
+`,
+			want:   "HELLO",
+			wantOk: true,
+		},
+		{ // carriage returns and indented lines
+			b:      "  -----BEGIN OTP-----\r\n\tSEVM\r\n  TE8=  \r\n-----END OTP-----\r\n",
+			want:   "HELLO",
+			wantOk: true,
+		},
+		{ // other types of blocks are ignored
 			b: `-----BEGIN OTP-----
 SEVMTE8=
 -----END OTP-----
-extra data which should not be in pem file
+-----BEGIN PGP SIGNATURE-----
+SEVMTE8=
+-----END PGP SIGNATURE-----
 `,
+			want:   "HELLO",
+			wantOk: true,
 		},
 		{
 			b: `-----BEGIN other-pem-----
 SEVMTE8=
 -----END other-pem-----
 `,
-			want:   "HELLO",
-			wantOk: true,
+		},
+		{ // multiple blocks
+			b: `-----BEGIN OTP-----
+SEVMTE8=
+-----END OTP-----
+-----BEGIN OTP-----
+SEVMTE8=
+-----END OTP-----
+`,
 		},
 		{
 			b: "",
@@ -67,3 +99,140 @@ SEVMTE8=
 		}
 	}
 }
+
+func TestDecodeStrict(t *testing.T) {
+	decodeStrictTests := []struct {
+		b      string
+		want   string
+		wantOk bool
+	}{
+		{
+			b: `-----BEGIN OTP-----
+SEVMTE8=
+-----END OTP-----
+`,
+			want:   "HELLO",
+			wantOk: true,
+		},
+		{
+			b: `-----BEGIN OTP-----
+SEVMTE8=
+-----END OTP-----
+extra data which should not be in pem file
+`,
+		},
+		{
+			b: `extra data which should not be in pem file
+-----BEGIN OTP-----
+SEVMTE8=
+-----END OTP-----
+`,
+		},
+		{
+			b: `-----BEGIN other-pem-----
+SEVMTE8=
+-----END other-pem-----
+`,
+		},
+		{
+			b: "",
+		},
+		{
+			b: `---INVALID PEM---`,
+		},
+	}
+	for i, test := range decodeStrictTests {
+		blk, err := decodeStrict([]byte(test.b))
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != string(blk.Bytes):
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, test.want, string(blk.Bytes))
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	validateTests := []struct {
+		text   string
+		wantOk bool
+	}{
+		{
+			text: `-----BEGIN OTP-----
+SEVMTE8=
+-----END OTP-----
+`,
+			wantOk: true,
+		},
+		{
+			text: "SEVMTE8=",
+		},
+		{},
+	}
+	for i, test := range validateTests {
+		err := Validate([]byte(test.text))
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		}
+	}
+}
+
+func TestValidateBundle(t *testing.T) {
+	block := `-----BEGIN OTP-----
+SEVMTE8=
+-----END OTP-----
+`
+	corrupt := `-----BEGIN OTP-----
+SEVMTE8
+-----END OTP-----
+`
+	validateBundleTests := []struct {
+		text    string
+		want    int
+		wantErr error
+	}{
+		{
+			wantErr: ErrNoPEM,
+		},
+		{
+			text: block,
+			want: 1,
+		},
+		{
+			text: "two blocks:\n" + block + "and\n" + block,
+			want: 2,
+		},
+		{
+			text:    block + corrupt + block,
+			want:    2,
+			wantErr: ErrInvalidBlock,
+		},
+		{
+			text:    corrupt,
+			wantErr: ErrInvalidBlock,
+		},
+	}
+	for i, test := range validateBundleTests {
+		got, err := ValidateBundle([]byte(test.text))
+		switch {
+		case test.wantErr != nil:
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("test %v: wanted %v, got %v", i, test.wantErr, err)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		}
+		if test.want != got {
+			t.Errorf("test %v: wanted %v blocks, got %v", i, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package otp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+const (
+	// protectionHeader is the key header that names how the key bytes are encrypted with a passphrase.
+	protectionHeader = "Protection"
+	// saltHeader is the key header with the base64 salt used to derive the lock from the passphrase.
+	saltHeader = "Salt"
+	// iterationsHeader is the key header with the number of iterations used to derive the lock from the passphrase.
+	iterationsHeader = "Iterations"
+	// nonceHeader is the key header with the base64 nonce used to seal the key bytes.
+	nonceHeader = "Nonce"
+	// pbkdf2AESGCM is the protection of keys that are sealed with a Lock.
+	pbkdf2AESGCM = "pbkdf2-sha256-aes-256-gcm"
+	// maxProtectionIterations limits the work of unprotecting keys with headers that were changed.
+	maxProtectionIterations = 10 * PassphraseIterations
+)
+
+// Protect encrypts the bytes of each OTP block in the text with a key derived from the passphrase.
+// The salt, iterations, and nonce are recorded in the headers of each block.
+// Other headers, such as the label and members, are not encrypted, but they are authenticated, so the block cannot be unprotected if they are changed.
+// Protected keys must be unprotected before they can encrypt or decrypt messages.
+func Protect(text []byte, passphrase string) ([]byte, error) {
+	return protect(text, passphrase, PassphraseIterations)
+}
+
+// protect encrypts the bytes of each OTP block in the text with a lock derived from the passphrase with the number of iterations.
+func protect(text []byte, passphrase string, iterations int) ([]byte, error) {
+	salt, err := NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	l, err := NewLock(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	blocks := findBlocks(text)
+	if len(blocks) == 0 {
+		return nil, ErrNoPEM
+	}
+	var buf bytes.Buffer
+	for i, blk := range blocks {
+		if _, ok := blk.Headers[protectionHeader]; ok {
+			return nil, fmt.Errorf("block %d: %w", i, ErrProtectedKey)
+		}
+		headers := make(map[string]string, len(blk.Headers)+4)
+		for name, value := range blk.Headers {
+			headers[name] = value
+		}
+		headers[protectionHeader] = pbkdf2AESGCM
+		headers[saltHeader] = base64.StdEncoding.EncodeToString(salt)
+		headers[iterationsHeader] = strconv.Itoa(iterations)
+		sealed, err := l.seal(blk.Bytes, headerData(headers))
+		clear(blk.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		n := l.aead.NonceSize()
+		headers[nonceHeader] = base64.StdEncoding.EncodeToString(sealed[:n])
+		b, err := encodeBlock(sealed[n:], headers)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+// Unprotect decrypts the bytes of each protected OTP block in the text with the passphrase.
+// Blocks that are not protected are kept as they are.
+func Unprotect(text []byte, passphrase string) ([]byte, error) {
+	blocks := findBlocks(text)
+	if len(blocks) == 0 {
+		return nil, ErrNoPEM
+	}
+	locks := make(map[string]*Lock)
+	var buf bytes.Buffer
+	for i, blk := range blocks {
+		if _, ok := blk.Headers[protectionHeader]; ok {
+			if err := unprotectBlock(blk, passphrase, locks); err != nil {
+				return nil, fmt.Errorf("block %d: %w", i, err)
+			}
+		}
+		b, err := encodeBlock(blk.Bytes, blk.Headers)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+// IsProtected reports whether any OTP block in the text is protected by a passphrase.
+func IsProtected(text []byte) bool {
+	for _, blk := range findBlocks(text) {
+		if _, ok := blk.Headers[protectionHeader]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// headerData encodes the headers of a protected block, other than the nonce, in order to authenticate them with the sealed bytes.
+// Header names cannot have colons and values cannot have newlines, so different headers are never encoded the same way.
+func headerData(headers map[string]string) []byte {
+	var buf bytes.Buffer
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		if name == nonceHeader {
+			continue
+		}
+		buf.WriteString(name + ": " + headers[name] + "\n")
+	}
+	return buf.Bytes()
+}
+
+// unprotectBlock replaces the bytes of the protected block with the decrypted bytes and removes the protection headers.
+// Locks are reused for blocks with the same salt and iterations because deriving them is slow.
+func unprotectBlock(blk *pem.Block, passphrase string, locks map[string]*Lock) error {
+	if p := blk.Headers[protectionHeader]; p != pbkdf2AESGCM {
+		return fmt.Errorf("unknown protection: %q", p)
+	}
+	salt, err := base64.StdEncoding.DecodeString(blk.Headers[saltHeader])
+	if err != nil {
+		return fmt.Errorf("decoding salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(blk.Headers[iterationsHeader])
+	switch {
+	case err != nil:
+		return fmt.Errorf("parsing iterations: %w", err)
+	case iterations > maxProtectionIterations:
+		return fmt.Errorf("too many iterations: %d", iterations)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(blk.Headers[nonceHeader])
+	if err != nil {
+		return fmt.Errorf("decoding nonce: %w", err)
+	}
+	id := blk.Headers[saltHeader] + ":" + blk.Headers[iterationsHeader]
+	l, ok := locks[id]
+	if !ok {
+		l, err = NewLock(passphrase, salt, iterations)
+		if err != nil {
+			return err
+		}
+		locks[id] = l
+	}
+	b, err := l.open(append(nonce, blk.Bytes...), headerData(blk.Headers))
+	if err != nil {
+		return err
+	}
+	blk.Bytes = b // the sealed bytes are not secret
+	for _, h := range []string{protectionHeader, saltHeader, iterationsHeader, nonceHeader} {
+		delete(blk.Headers, h)
+	}
+	return nil
+}
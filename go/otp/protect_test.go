@@ -0,0 +1,103 @@
+package otp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProtectUnprotect(t *testing.T) {
+	keys := `-----BEGIN OTP-----
+Label: lunch plans
+
+AQIDBAU=
+-----END OTP-----
+-----BEGIN OTP-----
+BgcICQo=
+-----END OTP-----
+`
+	protected, err := protect([]byte(keys), "secret", 1)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	switch {
+	case !IsProtected(protected):
+		t.Errorf("wanted keys to be protected")
+	case strings.Contains(string(protected), "AQIDBAU="):
+		t.Errorf("wanted key bytes to be encrypted, got %v", string(protected))
+	case !strings.Contains(string(protected), "Label: lunch plans"):
+		t.Errorf("wanted label to be kept, got %v", string(protected))
+	}
+	if _, err := Encrypt("CAT", []byte(strings.SplitAfter(string(protected), "-----END OTP-----\n")[0])); !errors.Is(err, ErrProtectedKey) {
+		t.Errorf("wanted protected key error when encrypting, got %v", err)
+	}
+	if _, err := ParseKeyring(protected); !errors.Is(err, ErrProtectedKey) {
+		t.Errorf("wanted protected key error when parsing keyring, got %v", err)
+	}
+	if _, err := protect(protected, "secret", 1); !errors.Is(err, ErrProtectedKey) {
+		t.Errorf("wanted protected key error when protecting twice, got %v", err)
+	}
+	if _, err := Unprotect(protected, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wanted wrong passphrase error, got %v", err)
+	}
+	got, err := Unprotect(protected, "secret")
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case keys != string(got):
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", keys, string(got))
+	case IsProtected(got):
+		t.Errorf("wanted keys to not be protected")
+	}
+}
+
+func TestUnprotectUnprotected(t *testing.T) {
+	key := `-----BEGIN OTP-----
+AQIDBAU=
+-----END OTP-----
+`
+	got, err := Unprotect([]byte("a key:\n"+key), "secret")
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case key != string(got):
+		t.Errorf("not equal\nwanted: %v\ngot:    %v", key, string(got))
+	}
+	if _, err := Unprotect(nil, "secret"); !errors.Is(err, ErrNoPEM) {
+		t.Errorf("wanted no PEM error, got %v", err)
+	}
+}
+
+func TestUnprotectChangedHeaders(t *testing.T) {
+	key := `-----BEGIN OTP-----
+Label: lunch plans
+Members: alice,bob
+
+AQIDBAU=
+-----END OTP-----
+`
+	protected, err := protect([]byte(key), "secret", 1)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	changes := []struct {
+		old, new string
+	}{
+		{"Label: lunch plans", "Label: dinner plans"},
+		{"Members: alice,bob", "Members: alice,eve"},
+		{"Iterations: 1", "Iterations: 2"},
+		{"Members: alice,bob\n", "Members: alice,bob\nPartner: eve\n"},
+	}
+	for i, test := range changes {
+		changed := strings.Replace(string(protected), test.old, test.new, 1)
+		if changed == string(protected) {
+			t.Fatalf("test %v: header not changed", i)
+		}
+		if _, err := Unprotect([]byte(changed), "secret"); !errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("test %v: wanted changed headers to be detected, got %v", i, err)
+		}
+	}
+	if _, err := Unprotect(protected, "secret"); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+}
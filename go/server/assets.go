@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// publicDir contains the templates that are rendered at their paths in the directory, such as /manifest.json.
+	publicDir = "resources/public"
+	// staticDir contains the resources that are served unchanged at their paths in the directory, such as /robots.txt.
+	staticDir = "resources/static"
+	// buildDir contains the built files that are served unchanged at their paths in the directory, such as /main.wasm.
+	buildDir = "build"
+	// publicTemplatePrefix starts the names of templates in the public directory.
+	// It separates the templates that are served from the templates they include.
+	publicTemplatePrefix = "public/"
+)
+
+// assetDir is a directory of files that are served unchanged.
+type assetDir struct {
+	fsys fs.FS
+	root string
+}
+
+// parsePublicTemplates adds the templates of the public directory, named by their paths in the directory.
+// The public directory does not have to exist.
+func parsePublicTemplates(t *template.Template, fsys fs.FS) error {
+	return fs.WalkDir(fsys, publicDir, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil && path == publicDir:
+			return nil // no public directory
+		case err != nil:
+			return err
+		case d.IsDir():
+			return nil
+		}
+		b, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return fmt.Errorf("reading public template: %v", err)
+		}
+		name := publicTemplatePrefix + strings.TrimPrefix(path, publicDir+"/")
+		if _, err := t.New(name).Parse(string(b)); err != nil {
+			return fmt.Errorf("parsing public template: %v", err)
+		}
+		return nil
+	})
+}
+
+// serveAsset serves the public template or the file of the static or build directories at the path of the request.
+// Other paths, including directories and paths that leave the directories, are not found.
+func (s Server) serveAsset(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if !fs.ValidPath(name) || strings.Contains(name, `\`) {
+		s.httpError(w, http.StatusNotFound)
+		return
+	}
+	if s.tmpl.Lookup(publicTemplatePrefix+name) != nil {
+		s.serveTemplate(w, r, r.URL.Path)
+		return
+	}
+	for _, d := range s.assetDirs {
+		if d.fsys != nil && s.serveFile(w, r, d.fsys, d.root+"/"+name) {
+			return
+		}
+	}
+	s.httpError(w, http.StatusNotFound)
+}
+
+// serveFile serves the file with the name if it is a regular file, returning false if it is not.
+// Unlike http.ServeFileFS, requests for index.html files are not redirected to their directories.
+// Files have ETags so unchanged files are not sent again, and are sent compressed with gzip if the client accepts it and they were compressed.
+func (s Server) serveFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) bool {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	w.Header().Set("Cache-Control", s.cacheControl(r, name))
+	w.Header().Add("Vary", "Accept-Encoding")
+	a, ok := s.assets[name]
+	if ok {
+		w.Header().Set("ETag", a.etag)
+	}
+	var content io.ReadSeeker
+	switch {
+	case ok && a.gzip != nil && acceptsGzip(r):
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if len(contentType) == 0 {
+			contentType = "application/octet-stream" // the type cannot be detected from compressed content
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("ETag", gzipETag(a.etag))
+		content = bytes.NewReader(a.gzip)
+	default:
+		rs, ok := f.(io.ReadSeeker)
+		if !ok {
+			b, err := io.ReadAll(f)
+			if err != nil {
+				s.handleError(w, fmt.Errorf("reading %v: %v", name, err))
+				return true
+			}
+			rs = bytes.NewReader(b)
+		}
+		content = rs
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return true
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type (
+	// Branding names, describes, and colors the site.
+	// Empty fields are replaced with the fields of DefaultBranding.
+	Branding struct {
+		// Name is the title of the site.
+		Name string `json:"name"`
+		// ShortName is used when there is not room for the name, such as under app icons.
+		// Its first letter is drawn on the icon.
+		ShortName string `json:"shortName"`
+		// Description describes the site to search engines and link previews.
+		Description string `json:"description"`
+		// ThemeColor is the CSS color of the icon and the browser toolbar.
+		ThemeColor string `json:"themeColor"`
+		// BackgroundColor is the CSS color of the background of the icon and app splash screen.
+		BackgroundColor string `json:"backgroundColor"`
+		// Links are listed at the bottom of the about tab.
+		Links []Link `json:"links"`
+	}
+
+	// Link is a hyperlink to another site.
+	Link struct {
+		Text string `json:"text"`
+		URL  string `json:"url"`
+	}
+)
+
+// DefaultBranding is the branding of the site when it is not configured.
+func DefaultBranding() Branding {
+	return Branding{
+		Name:            "Sarah-OTP",
+		ShortName:       "S-OTP",
+		Description:     "a secure message-passing app",
+		ThemeColor:      "purple",
+		BackgroundColor: "white",
+		Links: []Link{
+			{Text: "Github", URL: "https://github.com/jacobpatterson1549/sarah-otp"},
+			{Text: "LinkedIn", URL: "https://www.linkedin.com/in/jacobpatterson1549"},
+		},
+	}
+}
+
+// withDefaults replaces the empty fields of the branding with the fields of DefaultBranding.
+func (b Branding) withDefaults() Branding {
+	d := DefaultBranding()
+	for _, f := range []struct {
+		value        *string
+		defaultValue string
+	}{
+		{&b.Name, d.Name},
+		{&b.ShortName, d.ShortName},
+		{&b.Description, d.Description},
+		{&b.ThemeColor, d.ThemeColor},
+		{&b.BackgroundColor, d.BackgroundColor},
+	} {
+		*f.value = strings.TrimSpace(*f.value)
+		if len(*f.value) == 0 {
+			*f.value = f.defaultValue
+		}
+	}
+	if b.Links == nil {
+		b.Links = d.Links
+	}
+	return b
+}
+
+// validate checks that the links of the branding can be followed.
+func (b Branding) validate() error {
+	for i, l := range b.Links {
+		switch {
+		case len(l.Text) == 0:
+			return fmt.Errorf("link %v has no text", i)
+		case len(l.URL) == 0:
+			return fmt.Errorf("link %q has no url", l.Text)
+		}
+	}
+	return nil
+}
+
+// templateData creates the template data of the branding.
+func (b Branding) templateData() map[string]any {
+	initial, _ := utf8.DecodeRuneInString(b.ShortName)
+	return map[string]any{
+		"Name":            b.Name,
+		"ShortName":       b.ShortName,
+		"Initial":         string(unicode.ToUpper(initial)),
+		"Description":     b.Description,
+		"ThemeColor":      b.ThemeColor,
+		"BackgroundColor": b.BackgroundColor,
+		"Links":           b.Links,
+	}
+}
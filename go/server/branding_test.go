@@ -0,0 +1,95 @@
+package server
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBrandingWithDefaults(t *testing.T) {
+	b := Branding{
+		Name:       " Example ",
+		ThemeColor: "navy",
+		Links:      []Link{},
+	}.withDefaults()
+	d := DefaultBranding()
+	switch {
+	case b.Name != "Example":
+		t.Errorf("wanted trimmed name, got %q", b.Name)
+	case b.ThemeColor != "navy":
+		t.Errorf("wanted theme color to be kept, got %q", b.ThemeColor)
+	case b.ShortName != d.ShortName, b.Description != d.Description, b.BackgroundColor != d.BackgroundColor:
+		t.Errorf("wanted default values for empty fields, got %v", b)
+	case len(b.Links) != 0:
+		t.Errorf("wanted empty links to remove default links, got %v", b.Links)
+	}
+	if b := (Branding{}).withDefaults(); len(b.Links) != len(d.Links) {
+		t.Errorf("wanted default links when links are not set, got %v", b.Links)
+	}
+}
+
+func TestServeBranding(t *testing.T) {
+	resourcesFS := &fstest.MapFS{
+		"resources/html/main.html": {Data: []byte(`{{.Name}} {{.Initial}}{{range .Links}} <a href="{{.URL}}">{{.Text}}</a>{{end}}`)},
+		"resources/main.css":       {},
+	}
+	newServerTests := []struct {
+		branding Branding
+		wantOk   bool
+		want     string
+	}{
+		{
+			wantOk: true,
+			want:   `Sarah-OTP S <a href="https://github.com/jacobpatterson1549/sarah-otp">Github</a> <a href="https://www.linkedin.com/in/jacobpatterson1549">LinkedIn</a>`,
+		},
+		{
+			branding: Branding{
+				Name:      "Example OTP",
+				ShortName: "ex",
+				Links: []Link{
+					{Text: "Help", URL: "https://example.com/help"},
+					{Text: "Bad", URL: "javascript:alert(1)"},
+				},
+			},
+			wantOk: true,
+			want:   `Example OTP E <a href="https://example.com/help">Help</a> <a href="#ZgotmplZ">Bad</a>`,
+		},
+		{
+			branding: Branding{
+				Links: []Link{{Text: "Help"}},
+			},
+		},
+		{
+			branding: Branding{
+				Links: []Link{{URL: "https://example.com"}},
+			},
+		},
+	}
+	for i, test := range newServerTests {
+		cfg := Config{
+			Log:         log.New(ioutil.Discard, "test", log.LstdFlags),
+			Port:        8001,
+			ResourcesFS: resourcesFS,
+			Branding:    test.branding,
+		}
+		s, err := cfg.NewServer()
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+			continue
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+			continue
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		s.server.Handler.ServeHTTP(w, r)
+		if got := w.Body.String(); test.want != got {
+			t.Errorf("test %v:\nwanted: %v\ngot:    %v", i, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// serveBuildInfo writes the description of the build of the server as json.
+func (s Server) serveBuildInfo(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s.buildInfo); err != nil {
+		s.Log.Printf("writing build info: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/buildinfo"
+)
+
+func TestServeBuildInfo(t *testing.T) {
+	cfg := Config{
+		Log:         log.New(ioutil.Discard, "test", log.LstdFlags),
+		Version:     "v1\n",
+		Port:        8001,
+		ResourcesFS: testResourcesFS,
+		BuildFS: fstest.MapFS{
+			"build/wasm_exec.js": {},
+			"build/main.wasm":    {Data: []byte("abc")},
+		},
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/build.json", nil)
+	s.server.Handler.ServeHTTP(w, r)
+	var got buildinfo.Info
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding build info: %v", err)
+	}
+	switch {
+	case w.Header().Get("Content-Type") != "application/json":
+		t.Errorf("wanted json, got %v", w.Header().Get("Content-Type"))
+	case got.Version != "v1":
+		t.Errorf("wanted version v1, got %q", got.Version)
+	case got.GoVersion != runtime.Version():
+		t.Errorf("wanted go version %v, got %v", runtime.Version(), got.GoVersion)
+	case len(got.Assets) != 4:
+		t.Errorf("wanted hashes of resources and build files, got %v", got.Assets)
+	case got.Assets["build/main.wasm"] != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad":
+		t.Errorf("wanted hash of main.wasm, got %v", got.Assets["build/main.wasm"])
+	}
+}
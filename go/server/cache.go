@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// versionQueryParam is the query parameter of urls with the version of the server, such as /main.wasm?v=1.
+	// Static and built files at versioned urls can be cached forever because their urls change when the version changes.
+	versionQueryParam = "v"
+	// immutableCacheControl caches static and built files at urls with the current version for a year without checking for changes.
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// htmlCacheControl makes pages be rendered again each time they are used, because each rendering has a new nonce for its content security policy.
+	// Pages are not cached by proxies so visitors do not share the nonces of their content security policies.
+	htmlCacheControl = "private, no-cache"
+	// revalidateCacheControl caches other files, which are checked for changes with their ETags each time they are used.
+	revalidateCacheControl = "no-cache"
+)
+
+// asset is a file that is served unchanged.
+type asset struct {
+	// etag is the entity tag of the file, which is a hash of its content.
+	etag string
+	// gzip is the file compressed with gzip, or nil if compressing does not make it smaller.
+	gzip []byte
+}
+
+// newAssets compresses the files of the directories and creates their entity tags from their hashes, by path.
+func newAssets(dirs []assetDir, hashes map[string]string) (map[string]asset, error) {
+	assets := make(map[string]asset)
+	for _, d := range dirs {
+		if d.fsys == nil {
+			continue
+		}
+		err := fs.WalkDir(d.fsys, d.root, func(path string, e fs.DirEntry, err error) error {
+			switch {
+			case err != nil && path == d.root:
+				return nil // no directory
+			case err != nil:
+				return err
+			case e.IsDir():
+				return nil
+			}
+			b, err := fs.ReadFile(d.fsys, path)
+			if err != nil {
+				return err
+			}
+			a := asset{
+				etag: `"` + hashes[path] + `"`,
+			}
+			if len(hashes[path]) == 0 {
+				a.etag = contentETag(b)
+			}
+			if z, err := gzipBytes(b); err == nil && len(z) < len(b) {
+				a.gzip = z
+			}
+			assets[path] = a
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("loading files in %v: %w", d.root, err)
+		}
+	}
+	return assets, nil
+}
+
+// gzipBytes compresses the bytes with gzip.
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cacheControl is the Cache-Control header of the response to the request for the file or template.
+// Templates are never immutable because they are rendered with data that can change without changing the version.
+func (s Server) cacheControl(r *http.Request, name string) string {
+	_, isAsset := s.assets[name]
+	switch {
+	case isAsset && len(s.version) != 0 && r.URL.Query().Get(versionQueryParam) == s.version:
+		return immutableCacheControl
+	case filepath.Ext(name) == ".html":
+		return htmlCacheControl
+	default:
+		return revalidateCacheControl
+	}
+}
+
+// contentETag creates a strong entity tag from the hash of the content.
+func contentETag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// gzipETag creates the entity tag of the gzip encoding of the content with the entity tag.
+// Encodings of content have different entity tags because they have different bytes.
+func gzipETag(etag string) string {
+	return strings.TrimSuffix(etag, `"`) + `-gzip"`
+}
+
+// etagMatches reports whether the If-None-Match header of the request has the entity tag, using weak comparison.
+func etagMatches(r *http.Request, etag string) bool {
+	for _, t := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the client accepts responses compressed with gzip.
+func acceptsGzip(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+}
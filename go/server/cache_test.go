@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etagMatchesTests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`"abc-gzip"`, false},
+		{`"ab"`, false},
+		{"*", true},
+	}
+	for i, test := range etagMatchesTests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("If-None-Match", test.ifNoneMatch)
+		if got := etagMatches(r, `"abc"`); test.want != got {
+			t.Errorf("test %v: wanted %v, got %v", i, test.want, got)
+		}
+	}
+	if want, got := `"abc-gzip"`, gzipETag(`"abc"`); want != got {
+		t.Errorf("wanted gzip etag %v, got %v", want, got)
+	}
+}
+
+func TestCaching(t *testing.T) {
+	wasm := bytes.Repeat([]byte("wasm"), 1000)
+	cfg := Config{
+		Log:     log.New(ioutil.Discard, "test", log.LstdFlags),
+		Version: "v1",
+		Port:    8001,
+		ResourcesFS: &fstest.MapFS{
+			"resources/html/main.html":          {Data: []byte(`<script nonce="{{.Nonce}}" src="/main.wasm?v={{.Version}}"></script>`)},
+			"resources/main.css":                {},
+			"resources/public/serviceWorker.js": {Data: []byte(`const cacheName = "cache-{{.Version}}";`)},
+			"resources/static/robots.txt":       {Data: []byte(`User-agent: *`)},
+		},
+		BuildFS: fstest.MapFS{
+			"build/wasm_exec.js": {},
+			"build/main.wasm":    {Data: wasm},
+		},
+		MailboxStore: NewMemoryMailboxStore(10),
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	get := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		s.server.Handler.ServeHTTP(w, r)
+		return w
+	}
+	cacheControlTests := []struct {
+		path string
+		want string
+	}{
+		{"/", htmlCacheControl},
+		{"/main.wasm?v=v1", immutableCacheControl},
+		{"/main.wasm?v=v0", revalidateCacheControl},
+		{"/main.wasm", revalidateCacheControl},
+		{"/serviceWorker.js", revalidateCacheControl},
+		{"/serviceWorker.js?v=v1", revalidateCacheControl},
+		{"/robots.txt", revalidateCacheControl},
+		{"/read/AAAAAAAAAAAAAAAAAAAAAA", "no-store"},
+	}
+	for i, test := range cacheControlTests {
+		if got := get(test.path, nil).Header().Get("Cache-Control"); test.want != got {
+			t.Errorf("test %v (%v): wanted cache control %q, got %q", i, test.path, test.want, got)
+		}
+	}
+	etagTests := []struct {
+		path    string
+		headers map[string]string
+	}{
+		{"/serviceWorker.js", nil},
+		{"/serviceWorker.js", map[string]string{"Accept-Encoding": "gzip"}},
+		{"/main.wasm?v=v1", nil},
+		{"/main.wasm?v=v1", map[string]string{"Accept-Encoding": "gzip"}},
+		{"/robots.txt", nil},
+	}
+	for i, test := range etagTests {
+		w := get(test.path, test.headers)
+		etag := w.Header().Get("ETag")
+		if w.Code != 200 || len(etag) == 0 {
+			t.Errorf("test %v (%v): wanted ok response with etag, got %v: %q", i, test.path, w.Code, etag)
+			continue
+		}
+		w2 := get(test.path, test.headers)
+		if want, got := etag, w2.Header().Get("ETag"); want != got {
+			t.Errorf("test %v (%v): wanted etag to be the same for each response, got %v and %v", i, test.path, want, got)
+		}
+		headers := map[string]string{"If-None-Match": etag}
+		for name, value := range test.headers {
+			headers[name] = value
+		}
+		w3 := get(test.path, headers)
+		switch {
+		case w3.Code != http.StatusNotModified:
+			t.Errorf("test %v (%v): wanted not modified status, got %v", i, test.path, w3.Code)
+		case w3.Body.Len() != 0:
+			t.Errorf("test %v (%v): wanted no body, got %q", i, test.path, w3.Body.String())
+		}
+		headers["If-None-Match"] = `"other"`
+		if w4 := get(test.path, headers); w4.Code != http.StatusOK {
+			t.Errorf("test %v (%v): wanted ok status when etag does not match, got %v", i, test.path, w4.Code)
+		}
+	}
+	identity := get("/main.wasm?v=v1", nil)
+	compressed := get("/main.wasm?v=v1", map[string]string{"Accept-Encoding": "gzip, deflate"})
+	switch {
+	case identity.Header().Get("ETag") == compressed.Header().Get("ETag"):
+		t.Errorf("wanted different etags for compressed and uncompressed content")
+	case compressed.Header().Get("Content-Encoding") != "gzip":
+		t.Errorf("wanted compressed content, got encoding %q", compressed.Header().Get("Content-Encoding"))
+	case compressed.Header().Get("Content-Type") != "application/wasm":
+		t.Errorf("wanted wasm content type, got %q", compressed.Header().Get("Content-Type"))
+	case compressed.Body.Len() >= len(wasm):
+		t.Errorf("wanted compressed content to be smaller")
+	case !strings.Contains(compressed.Header().Get("Vary"), "Accept-Encoding"):
+		t.Errorf("wanted response to vary by encoding, got %q", compressed.Header().Get("Vary"))
+	}
+	gr, err := gzip.NewReader(compressed.Body)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if got, err := io.ReadAll(gr); err != nil || !bytes.Equal(wasm, got) {
+		t.Errorf("wanted compressed content to be wasm, got error %v", err)
+	}
+	if etag := get("/read/AAAAAAAAAAAAAAAAAAAAAA", nil).Header().Get("ETag"); len(etag) != 0 {
+		t.Errorf("wanted read links to not have etags, got %v", etag)
+	}
+	page := get("/", map[string]string{"If-None-Match": "*"})
+	switch {
+	case page.Code != http.StatusOK:
+		t.Errorf("wanted page with nonce to be rendered again, got %v", page.Code)
+	case len(page.Header().Get("ETag")) != 0:
+		t.Errorf("wanted page with nonce to not have etag, got %v", page.Header().Get("ETag"))
+	case !strings.Contains(page.Header().Get("Content-Security-Policy"), "nonce-"):
+		t.Errorf("wanted page with nonce to have content security policy for its nonce, got %q", page.Header().Get("Content-Security-Policy"))
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// chatPath is the path of the websockets of chat rooms.
+	chatPath = "/chat"
+	// maxChatMessageSize is the maximum number of bytes of cipher text in a chat message.
+	maxChatMessageSize = 64 << 10
+	// maxChatRoomSize is the maximum number of participants in a chat room.
+	maxChatRoomSize = 16
+	// maxChatRooms is the maximum number of chat rooms with participants.
+	maxChatRooms = 1024
+	// chatIdleTimeout is how long a participant can go without sending a frame before it is closed.
+	// Idle participants would otherwise hold their places in full rooms forever.
+	chatIdleTimeout = 10 * time.Minute
+)
+
+// chatRoomPattern matches valid chat room names, such as key fingerprints.
+var chatRoomPattern = regexp.MustCompile(`^[A-Za-z0-9_:.-]{1,64}$`)
+
+// chatHub relays messages between the participants of chat rooms.
+// Messages are opaque cipher text that is not kept after it is relayed.
+type chatHub struct {
+	mu          sync.Mutex
+	rooms       map[string]map[*websocketConn]struct{}
+	idleTimeout time.Duration
+}
+
+// newChatHub creates a hub with no rooms.
+func newChatHub() *chatHub {
+	h := chatHub{
+		rooms:       make(map[string]map[*websocketConn]struct{}),
+		idleTimeout: chatIdleTimeout,
+	}
+	return &h
+}
+
+// handleChat upgrades the request to a websocket in the chat room of the path.
+// GET /chat/{room} relays each text message from the websocket to the other participants in the room.
+func (s Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	room := strings.TrimPrefix(r.URL.Path, chatPath+"/")
+	if !chatRoomPattern.MatchString(room) {
+		s.httpError(w, http.StatusNotFound)
+		return
+	}
+	if !s.chat.hasRoom(room) {
+		s.httpError(w, http.StatusServiceUnavailable)
+		return
+	}
+	c, err := upgradeWebsocket(w, r)
+	if err != nil {
+		return
+	}
+	c.idleTimeout = s.chat.idleTimeout
+	if err := s.chat.join(room, c); err != nil {
+		c.Close(closeGoingAway)
+		return
+	}
+	defer s.chat.leave(room, c)
+	for {
+		opcode, message, err := c.ReadMessage(maxChatMessageSize)
+		switch {
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			c.Close(closeGoingAway)
+			return
+		case err != nil:
+			if !errors.Is(err, errWebsocketClosed) {
+				c.Close(closeProtocol)
+			}
+			return
+		case opcode != opText:
+			c.Close(closeUnsupported)
+			return
+		}
+		s.chat.relay(room, c, message)
+	}
+}
+
+// hasRoom reports whether or not another participant can join the room.
+func (h *chatHub) hasRoom(room string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	participants, ok := h.rooms[room]
+	if !ok {
+		return len(h.rooms) < maxChatRooms
+	}
+	return len(participants) < maxChatRoomSize
+}
+
+// join adds the participant to the room, creating it if needed.
+func (h *chatHub) join(room string, c *websocketConn) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	participants, ok := h.rooms[room]
+	switch {
+	case !ok && len(h.rooms) >= maxChatRooms:
+		return errors.New("too many chat rooms")
+	case !ok:
+		participants = make(map[*websocketConn]struct{})
+		h.rooms[room] = participants
+	case len(participants) >= maxChatRoomSize:
+		return errors.New("chat room full")
+	}
+	participants[c] = struct{}{}
+	return nil
+}
+
+// leave removes the participant from the room, deleting the room if it is empty.
+func (h *chatHub) leave(room string, c *websocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	participants := h.rooms[room]
+	delete(participants, c)
+	if len(participants) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// relay writes the message to the participants in the room other than the sender.
+// Participants that cannot be written to are closed.
+func (h *chatHub) relay(room string, sender *websocketConn, message []byte) {
+	h.mu.Lock()
+	var others []*websocketConn
+	for c := range h.rooms[room] {
+		if c != sender {
+			others = append(others, c)
+		}
+	}
+	h.mu.Unlock()
+	for _, c := range others {
+		if err := c.WriteMessage(opText, message); err != nil {
+			c.Close(closeGoingAway)
+		}
+	}
+}
+
+// closeAll closes the connections of all participants, such as when the server shuts down.
+func (h *chatHub) closeAll() {
+	h.mu.Lock()
+	var all []*websocketConn
+	for _, participants := range h.rooms {
+		for c := range participants {
+			all = append(all, c)
+		}
+	}
+	h.mu.Unlock()
+	for _, c := range all {
+		c.Close(closeGoingAway)
+	}
+}
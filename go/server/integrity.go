@@ -0,0 +1,37 @@
+package server
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+)
+
+// integrityAssets are the template data keys of the integrity metadata of build files, by the name of the file.
+// Browsers refuse to run the files if they do not match their metadata, so a changed build cannot read keys.
+var integrityAssets = map[string]string{
+	"build/wasm_exec.js": "WasmExecIntegrity",
+	"build/main.wasm":    "MainWasmIntegrity",
+}
+
+// addIntegrity adds the integrity metadata of the build files to the template data.
+func addIntegrity(data map[string]any, buildFS fs.FS) error {
+	for name, key := range integrityAssets {
+		integrity, err := subresourceIntegrity(buildFS, name)
+		if err != nil {
+			return err
+		}
+		data[key] = integrity
+	}
+	return nil
+}
+
+// subresourceIntegrity creates the SHA-384 integrity metadata of the file for script tags and fetch requests.
+func subresourceIntegrity(fsys fs.FS, name string) (string, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("reading %v to compute its integrity: %w", name, err)
+	}
+	sum := sha512.Sum384(b)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:]), nil
+}
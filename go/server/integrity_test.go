@@ -0,0 +1,78 @@
+package server
+
+import (
+	"html"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSubresourceIntegrity(t *testing.T) {
+	fsys := fstest.MapFS{
+		"empty.txt": {},
+		"abc.txt":   {Data: []byte("abc")},
+	}
+	subresourceIntegrityTests := []struct {
+		name   string
+		wantOk bool
+		want   string
+	}{
+		{"empty.txt", true, "sha384-OLBgp1GsljhM2TJ+sbHjaiH9txEUvgdDTAzHv2P24donTt6/529l+9Ua0vFImLlb"},
+		{"abc.txt", true, "sha384-ywB1P0WjXou1oD1pmsZQBycsMqsO3tFjGotgWkP/W+2AhgcroefMI1i67KE0yCWn"},
+		{"missing.txt", false, ""},
+	}
+	for i, test := range subresourceIntegrityTests {
+		got, err := subresourceIntegrity(fsys, test.name)
+		switch {
+		case !test.wantOk:
+			if err == nil {
+				t.Errorf("test %v: wanted error", i)
+			}
+		case err != nil:
+			t.Errorf("test %v: unwanted error: %v", i, err)
+		case test.want != got:
+			t.Errorf("test %v: wanted %v, got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestServeIntegrity(t *testing.T) {
+	resourcesFS := &fstest.MapFS{
+		"resources/html/main.html": {Data: []byte(`<script src="/wasm_exec.js" integrity="{{.WasmExecIntegrity}}"></script><script>{{template "init.js" .}}</script>`)},
+		"resources/init.js":        {Data: []byte(`fetch("/main.wasm", { integrity: {{.MainWasmIntegrity}} });`)},
+	}
+	buildFS := fstest.MapFS{
+		"build/wasm_exec.js": {},
+		"build/main.wasm":    {Data: []byte("abc")},
+	}
+	cfg := Config{
+		Log:         log.New(ioutil.Discard, "test", log.LstdFlags),
+		Port:        8001,
+		ResourcesFS: resourcesFS,
+		BuildFS:     buildFS,
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	s.server.Handler.ServeHTTP(w, r)
+	body := html.UnescapeString(w.Body.String())
+	wantParts := []string{
+		`integrity="sha384-OLBgp1GsljhM2TJ+sbHjaiH9txEUvgdDTAzHv2P24donTt6/529l+9Ua0vFImLlb"`,
+		`integrity: "sha384-ywB1P0WjXou1oD1pmsZQBycsMqsO3tFjGotgWkP/W+2AhgcroefMI1i67KE0yCWn"`,
+	}
+	for i, want := range wantParts {
+		if !strings.Contains(body, want) {
+			t.Errorf("test %v: wanted rendered page to contain %v, got %v", i, want, body)
+		}
+	}
+	delete(buildFS, "build/main.wasm")
+	if _, err := cfg.NewServer(); err == nil {
+		t.Errorf("wanted error creating server when build file is missing")
+	}
+}
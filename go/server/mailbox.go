@@ -0,0 +1,559 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// mailboxPath is the path of the mailbox API.
+	mailboxPath = "/mailbox"
+	// mailboxIDLength is the number of random bytes in mailbox ids.
+	mailboxIDLength = 16
+	// DefaultMailboxMaxSize is the default maximum number of bytes of cipher text in a mailbox.
+	// It is large enough for messages that use a whole key of the largest size.
+	DefaultMailboxMaxSize = 128 << 10
+	// DefaultMailboxTTL is the default time mailboxes are kept before they expire.
+	DefaultMailboxTTL = 24 * time.Hour
+	// mailboxExpireInterval is how often expired mailboxes are removed.
+	mailboxExpireInterval = time.Minute
+	// receiptPath is the path of mailbox receipts.
+	receiptPath = "/receipt"
+	// receiptSuffix is the suffix of receipt file names.
+	receiptSuffix = ".receipt"
+	// readPath is the path of links that open the decrypt page with the cipher text in a mailbox.
+	readPath = "/read"
+)
+
+var (
+	// ErrMailboxNotFound is returned when a mailbox does not exist, was already taken, or has expired.
+	ErrMailboxNotFound = errors.New("mailbox not found")
+	// ErrReceiptNotFound is returned when a receipt does not exist or has expired.
+	ErrReceiptNotFound = errors.New("receipt not found")
+	// ErrMailboxFull is returned when the store cannot hold more mailboxes.
+	ErrMailboxFull = errors.New("no room for more mailboxes")
+	// mailboxIDPattern matches valid mailbox ids and receipt tokens, which are safe to use as file names.
+	mailboxIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`)
+)
+
+type (
+	// MailboxStore holds opaque cipher text in mailboxes until it is taken once or expires.
+	// Each mailbox has a receipt that records when it was taken.
+	MailboxStore interface {
+		// Put stores the mailbox and its receipt.
+		Put(m Mailbox) error
+		// Take removes the data from the mailbox, returns it, and records the time it was taken on the receipt.
+		// ErrMailboxNotFound is returned if the mailbox does not exist or expired before now.
+		Take(id string, now time.Time) ([]byte, error)
+		// Receipt returns the receipt with the token.
+		// ErrReceiptNotFound is returned if the receipt does not exist or expired before now.
+		Receipt(token string, now time.Time) (*Receipt, error)
+		// Expire removes the mailboxes and receipts that expired before now.
+		Expire(now time.Time) error
+	}
+
+	// Mailbox holds cipher text.
+	Mailbox struct {
+		// ID is the random name of the mailbox, which is needed to take its data.
+		ID string
+		// ReceiptToken is the random name of the receipt of the mailbox, which is given to the sender.
+		ReceiptToken string
+		// Data is the cipher text.
+		Data []byte
+		// Expires is when the mailbox is removed if it is not taken.
+		Expires time.Time
+		// ReceiptExpires is when the receipt is removed.
+		// It should be after the mailbox expires so the sender can learn whether or not it was taken.
+		ReceiptExpires time.Time
+	}
+
+	// Receipt tells the sender whether or not a mailbox was taken.
+	Receipt struct {
+		// Expires is when the receipt is removed.
+		Expires time.Time `json:"expires"`
+		// Fetched is when the mailbox was taken, or nil if it has not been taken.
+		Fetched *time.Time `json:"fetched,omitempty"`
+	}
+
+	// MemoryMailboxStore keeps mailboxes in memory, so they are lost when the server stops.
+	MemoryMailboxStore struct {
+		maxMailboxes int
+		mu           sync.Mutex
+		mailboxes    map[string]Mailbox
+		receipts     map[string]Receipt
+	}
+
+	// FileMailboxStore keeps each mailbox and receipt in a file in a directory.
+	// Mailbox files start with the expiry time, in unix seconds, and the receipt token, followed by the data.
+	// Receipt files are named by the token with a ".receipt" suffix and hold the expiry and fetched times in unix seconds.
+	// Like a MemoryMailboxStore, it holds a maximum number of mailboxes so clients cannot fill the disk.
+	FileMailboxStore struct {
+		dir          string
+		maxMailboxes int
+		mu           sync.Mutex
+	}
+
+	// mailboxResponse describes a new mailbox.
+	mailboxResponse struct {
+		ID      string    `json:"id"`
+		Expires time.Time `json:"expires"`
+		Receipt string    `json:"receipt"`
+		Link    string    `json:"link"`
+	}
+)
+
+// NewMemoryMailboxStore creates a store that holds up to the maximum number of mailboxes.
+// Receipts of taken mailboxes count toward the maximum until they expire.
+func NewMemoryMailboxStore(maxMailboxes int) *MemoryMailboxStore {
+	s := MemoryMailboxStore{
+		maxMailboxes: maxMailboxes,
+		mailboxes:    make(map[string]Mailbox),
+		receipts:     make(map[string]Receipt),
+	}
+	return &s
+}
+
+// Put stores the mailbox and its receipt.
+func (s *MemoryMailboxStore) Put(m Mailbox) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.mailboxes) >= s.maxMailboxes || len(s.receipts) >= s.maxMailboxes {
+		return ErrMailboxFull
+	}
+	if _, ok := s.mailboxes[m.ID]; ok {
+		return fmt.Errorf("mailbox already exists: %v", m.ID)
+	}
+	if _, ok := s.receipts[m.ReceiptToken]; ok {
+		return fmt.Errorf("receipt already exists: %v", m.ReceiptToken)
+	}
+	s.mailboxes[m.ID] = m
+	s.receipts[m.ReceiptToken] = Receipt{
+		Expires: m.ReceiptExpires,
+	}
+	return nil
+}
+
+// Take removes the data from the mailbox and returns it.
+func (s *MemoryMailboxStore) Take(id string, now time.Time) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.mailboxes[id]
+	if !ok {
+		return nil, ErrMailboxNotFound
+	}
+	delete(s.mailboxes, id)
+	if !now.Before(m.Expires) {
+		return nil, ErrMailboxNotFound
+	}
+	if r, ok := s.receipts[m.ReceiptToken]; ok {
+		r.Fetched = &now
+		s.receipts[m.ReceiptToken] = r
+	}
+	return m.Data, nil
+}
+
+// Receipt returns the receipt with the token.
+func (s *MemoryMailboxStore) Receipt(token string, now time.Time) (*Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.receipts[token]
+	if !ok || !now.Before(r.Expires) {
+		return nil, ErrReceiptNotFound
+	}
+	return &r, nil
+}
+
+// Expire removes the mailboxes and receipts that expired before now.
+func (s *MemoryMailboxStore) Expire(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, m := range s.mailboxes {
+		if !now.Before(m.Expires) {
+			delete(s.mailboxes, id)
+		}
+	}
+	for token, r := range s.receipts {
+		if !now.Before(r.Expires) {
+			delete(s.receipts, token)
+		}
+	}
+	return nil
+}
+
+// NewFileMailboxStore creates a store that keeps up to the maximum number of mailboxes in the directory, creating it if needed.
+// Receipts of taken mailboxes count toward the maximum until they expire.
+func NewFileMailboxStore(dir string, maxMailboxes int) (*FileMailboxStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating mailbox directory: %w", err)
+	}
+	s := FileMailboxStore{
+		dir:          dir,
+		maxMailboxes: maxMailboxes,
+	}
+	return &s, nil
+}
+
+// Put writes the receipt file and then the mailbox file.
+func (s *FileMailboxStore) Put(m Mailbox) error {
+	switch {
+	case !mailboxIDPattern.MatchString(m.ID):
+		return fmt.Errorf("invalid mailbox id: %q", m.ID)
+	case !mailboxIDPattern.MatchString(m.ReceiptToken):
+		return fmt.Errorf("invalid receipt token: %q", m.ReceiptToken)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mailboxes, receipts, err := s.count()
+	switch {
+	case err != nil:
+		return err
+	case mailboxes >= s.maxMailboxes || receipts >= s.maxMailboxes:
+		return ErrMailboxFull
+	}
+	r := Receipt{
+		Expires: m.ReceiptExpires,
+	}
+	if err := s.writeFile(m.ReceiptToken+receiptSuffix, r.fileBytes(), true); err != nil {
+		return fmt.Errorf("creating receipt: %w", err)
+	}
+	b := binary.BigEndian.AppendUint64(nil, uint64(m.Expires.Unix()))
+	b = append(b, m.ReceiptToken...)
+	b = append(b, m.Data...)
+	if err := s.writeFile(m.ID, b, true); err != nil {
+		os.Remove(filepath.Join(s.dir, m.ReceiptToken+receiptSuffix))
+		return fmt.Errorf("creating mailbox: %w", err)
+	}
+	return nil
+}
+
+// Take reads and removes the mailbox file, then records the time on its receipt file.
+// The file is renamed before it is read so only one request can take it.
+func (s *FileMailboxStore) Take(id string, now time.Time) ([]byte, error) {
+	if !mailboxIDPattern.MatchString(id) {
+		return nil, ErrMailboxNotFound
+	}
+	name := filepath.Join(s.dir, id)
+	taken := name + ".taken"
+	if err := os.Rename(name, taken); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrMailboxNotFound
+		}
+		return nil, fmt.Errorf("taking mailbox: %w", err)
+	}
+	defer os.Remove(taken)
+	b, err := os.ReadFile(taken)
+	if err != nil {
+		return nil, fmt.Errorf("reading mailbox: %w", err)
+	}
+	if len(b) < 8+len(id) {
+		return nil, fmt.Errorf("mailbox file too short")
+	}
+	expires := time.Unix(int64(binary.BigEndian.Uint64(b)), 0)
+	token := string(b[8 : 8+len(id)])
+	if !now.Before(expires) {
+		return nil, ErrMailboxNotFound
+	}
+	r, err := s.Receipt(token, now)
+	switch {
+	case errors.Is(err, ErrReceiptNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		r.Fetched = &now
+		if err := s.writeFile(token+receiptSuffix, r.fileBytes(), false); err != nil {
+			return nil, fmt.Errorf("writing receipt: %w", err)
+		}
+	}
+	return b[8+len(id):], nil
+}
+
+// Receipt reads the receipt file with the token.
+func (s *FileMailboxStore) Receipt(token string, now time.Time) (*Receipt, error) {
+	if !mailboxIDPattern.MatchString(token) {
+		return nil, ErrReceiptNotFound
+	}
+	b, err := os.ReadFile(filepath.Join(s.dir, token+receiptSuffix))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, ErrReceiptNotFound
+	case err != nil:
+		return nil, fmt.Errorf("reading receipt: %w", err)
+	case len(b) != 16:
+		return nil, fmt.Errorf("receipt file has wrong length: %d", len(b))
+	}
+	r := Receipt{
+		Expires: time.Unix(int64(binary.BigEndian.Uint64(b)), 0),
+	}
+	if fetched := int64(binary.BigEndian.Uint64(b[8:])); fetched != 0 {
+		t := time.Unix(fetched, 0)
+		r.Fetched = &t
+	}
+	if !now.Before(r.Expires) {
+		return nil, ErrReceiptNotFound
+	}
+	return &r, nil
+}
+
+// Expire removes the mailbox and receipt files that expired before now.
+func (s *FileMailboxStore) Expire(now time.Time) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("reading mailbox directory: %w", err)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		switch token, ok := strings.CutSuffix(name, receiptSuffix); {
+		case mailboxIDPattern.MatchString(name):
+			expires, err := s.mailboxExpires(name)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				// taken while the directory was read
+			case err != nil:
+				return err
+			case !now.Before(expires):
+				if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return fmt.Errorf("removing mailbox: %w", err)
+				}
+			}
+		case ok && mailboxIDPattern.MatchString(token):
+			_, err := s.Receipt(token, now)
+			switch {
+			case errors.Is(err, ErrReceiptNotFound):
+				if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+					return fmt.Errorf("removing receipt: %w", err)
+				}
+			case err != nil:
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// count counts the mailbox and receipt files in the directory.
+func (s *FileMailboxStore) count() (mailboxes, receipts int, err error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading mailbox directory: %w", err)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		switch token, ok := strings.CutSuffix(name, receiptSuffix); {
+		case mailboxIDPattern.MatchString(name):
+			mailboxes++
+		case ok && mailboxIDPattern.MatchString(token):
+			receipts++
+		}
+	}
+	return mailboxes, receipts, nil
+}
+
+// mailboxExpires reads the expiry time at the start of the mailbox file.
+func (s *FileMailboxStore) mailboxExpires(id string) (time.Time, error) {
+	f, err := os.Open(filepath.Join(s.dir, id))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(f, b); err != nil {
+		return time.Time{}, fmt.Errorf("reading mailbox expiry: %w", err)
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(b)), 0), nil
+}
+
+// writeFile writes the file in the directory, failing if the file should be new but already exists.
+func (s *FileMailboxStore) writeFile(name string, b []byte, create bool) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if create {
+		flag |= os.O_EXCL
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, name), flag, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// fileBytes encodes the expiry and fetched times of the receipt for a FileMailboxStore.
+func (r Receipt) fileBytes() []byte {
+	var fetched int64
+	if r.Fetched != nil {
+		fetched = r.Fetched.Unix()
+	}
+	b := binary.BigEndian.AppendUint64(nil, uint64(r.Expires.Unix()))
+	return binary.BigEndian.AppendUint64(b, uint64(fetched))
+}
+
+// newMailboxID creates a random, url-safe mailbox id or receipt token.
+func newMailboxID() (string, error) {
+	b := make([]byte, mailboxIDLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating mailbox id: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// handleMailbox puts cipher text in new mailboxes and takes it out of them.
+// POST /mailbox stores the body in a new mailbox and responds with its id, receipt token, and read link.
+// GET /mailbox/{id} responds with the cipher text and deletes the mailbox.
+func (s Server) handleMailbox(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	switch {
+	case r.Method == "POST" && r.URL.Path == mailboxPath:
+		s.postMailbox(w, r)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, mailboxPath+"/"):
+		id := strings.TrimPrefix(r.URL.Path, mailboxPath+"/")
+		s.getMailbox(w, id)
+	case r.URL.Path == mailboxPath, strings.HasPrefix(r.URL.Path, mailboxPath+"/"):
+		s.httpError(w, http.StatusMethodNotAllowed)
+	default:
+		s.httpError(w, http.StatusNotFound)
+	}
+}
+
+// postMailbox stores the request body in a new mailbox.
+func (s Server) postMailbox(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, s.mailboxMaxSize)
+	data, err := io.ReadAll(body)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		s.httpError(w, http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		s.httpError(w, http.StatusBadRequest)
+		return
+	case len(data) == 0:
+		http.Error(w, "empty cipher text", http.StatusBadRequest)
+		return
+	}
+	id, err := newMailboxID()
+	if err != nil {
+		s.handleError(w, err)
+		return
+	}
+	token, err := newMailboxID()
+	if err != nil {
+		s.handleError(w, err)
+		return
+	}
+	expires := time.Now().Add(s.mailboxTTL).Truncate(time.Second)
+	m := Mailbox{
+		ID:             id,
+		ReceiptToken:   token,
+		Data:           data,
+		Expires:        expires,
+		ReceiptExpires: expires.Add(s.mailboxTTL),
+	}
+	if err := s.mailboxStore.Put(m); err != nil {
+		if errors.Is(err, ErrMailboxFull) {
+			s.httpError(w, http.StatusServiceUnavailable)
+			return
+		}
+		s.handleError(w, fmt.Errorf("storing mailbox: %w", err))
+		return
+	}
+	resp := mailboxResponse{
+		ID:      id,
+		Expires: expires,
+		Receipt: token,
+		Link:    readPath + "/" + id,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", mailboxPath+"/"+id)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.Log.Printf("writing mailbox response: %v", err)
+	}
+}
+
+// getMailbox responds with the cipher text in the mailbox and deletes it.
+func (s Server) getMailbox(w http.ResponseWriter, id string) {
+	data, err := s.mailboxStore.Take(id, time.Now())
+	switch {
+	case errors.Is(err, ErrMailboxNotFound):
+		s.httpError(w, http.StatusNotFound)
+		return
+	case err != nil:
+		s.handleError(w, fmt.Errorf("taking mailbox: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(data)
+}
+
+// handleReceipt responds with the receipt of a mailbox, which tells the sender if and when it was fetched.
+// GET /receipt/{token} responds with the receipt as json.
+func (s Server) handleReceipt(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	token, ok := strings.CutPrefix(r.URL.Path, receiptPath+"/")
+	switch {
+	case !ok:
+		s.httpError(w, http.StatusNotFound)
+		return
+	case r.Method != "GET":
+		s.httpError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	receipt, err := s.mailboxStore.Receipt(token, time.Now())
+	switch {
+	case errors.Is(err, ErrReceiptNotFound):
+		s.httpError(w, http.StatusNotFound)
+		return
+	case err != nil:
+		s.handleError(w, fmt.Errorf("reading receipt: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(receipt); err != nil {
+		s.Log.Printf("writing receipt response: %v", err)
+	}
+}
+
+// serveReadLink renders the main page with the decrypt tab ready to fetch the mailbox.
+// The mailbox is not taken until the user fetches it from the page, so link previews and crawlers that get the link do not delete it.
+func (s Server) serveReadLink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	id := strings.TrimPrefix(r.URL.Path, readPath+"/")
+	data := s.templateData(r)
+	data["ReadLink"] = "true"
+	data["MailboxID"] = id
+	s.serveTemplateData(w, r, "main.html", http.StatusOK, data)
+}
+
+// expireMailboxes removes expired mailboxes periodically until the context is done.
+func (s Server) expireMailboxes(ctx context.Context) {
+	ticker := time.NewTicker(mailboxExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := s.mailboxStore.Expire(now); err != nil {
+				s.Log.Printf("expiring mailboxes: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var testResourcesFS = &fstest.MapFS{
+	"resources/html/main.html": {},
+	"resources/main.css":       {},
+}
+
+func TestMailboxStores(t *testing.T) {
+	fileStore, err := NewFileMailboxStore(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	stores := map[string]MailboxStore{
+		"memory": NewMemoryMailboxStore(10),
+		"file":   fileStore,
+	}
+	now := time.Unix(1000, 0)
+	newMailbox := func(name string) Mailbox {
+		id, err := newMailboxID()
+		if err != nil {
+			t.Fatalf("%v: unwanted error: %v", name, err)
+		}
+		token, err := newMailboxID()
+		if err != nil {
+			t.Fatalf("%v: unwanted error: %v", name, err)
+		}
+		m := Mailbox{
+			ID:             id,
+			ReceiptToken:   token,
+			Data:           []byte("cipher text"),
+			Expires:        now.Add(time.Minute),
+			ReceiptExpires: now.Add(time.Hour),
+		}
+		if err := stores[name].Put(m); err != nil {
+			t.Fatalf("%v: unwanted error: %v", name, err)
+		}
+		return m
+	}
+	for name, s := range stores {
+		m := newMailbox(name)
+		r, err := s.Receipt(m.ReceiptToken, now)
+		switch {
+		case err != nil:
+			t.Errorf("%v: unwanted error: %v", name, err)
+		case r.Fetched != nil:
+			t.Errorf("%v: wanted receipt to not be fetched before mailbox is taken", name)
+		case !r.Expires.Equal(m.ReceiptExpires):
+			t.Errorf("%v: wanted receipt to expire at %v, got %v", name, m.ReceiptExpires, r.Expires)
+		}
+		fetched := now.Add(time.Second)
+		got, err := s.Take(m.ID, fetched)
+		switch {
+		case err != nil:
+			t.Errorf("%v: unwanted error: %v", name, err)
+		case string(m.Data) != string(got):
+			t.Errorf("%v: wanted %q, got %q", name, m.Data, got)
+		}
+		if _, err := s.Take(m.ID, now); !errors.Is(err, ErrMailboxNotFound) {
+			t.Errorf("%v: wanted mailbox to only be taken once, got %v", name, err)
+		}
+		r, err = s.Receipt(m.ReceiptToken, now.Add(time.Minute))
+		switch {
+		case err != nil:
+			t.Errorf("%v: unwanted error: %v", name, err)
+		case r.Fetched == nil, !r.Fetched.Equal(fetched):
+			t.Errorf("%v: wanted receipt to be fetched at %v, got %v", name, fetched, r.Fetched)
+		}
+		m = newMailbox(name)
+		if _, err := s.Take(m.ID, m.Expires); !errors.Is(err, ErrMailboxNotFound) {
+			t.Errorf("%v: wanted expired mailbox to not be found, got %v", name, err)
+		}
+		if r, err := s.Receipt(m.ReceiptToken, now); err != nil || r.Fetched != nil {
+			t.Errorf("%v: wanted receipt of expired mailbox to not be fetched, got %v, %v", name, r, err)
+		}
+		m = newMailbox(name)
+		if err := s.Expire(now); err != nil {
+			t.Errorf("%v: unwanted error: %v", name, err)
+		}
+		if got, err := s.Take(m.ID, now); err != nil || string(got) != string(m.Data) {
+			t.Errorf("%v: wanted mailbox to be kept until it expires, got %q, %v", name, got, err)
+		}
+		m = newMailbox(name)
+		if err := s.Expire(m.Expires); err != nil {
+			t.Errorf("%v: unwanted error: %v", name, err)
+		}
+		if _, err := s.Take(m.ID, now); !errors.Is(err, ErrMailboxNotFound) {
+			t.Errorf("%v: wanted mailbox to be removed when it expired, got %v", name, err)
+		}
+		if r, err := s.Receipt(m.ReceiptToken, m.Expires); err != nil || r.Fetched != nil {
+			t.Errorf("%v: wanted receipt of expired mailbox to not be fetched, got %v, %v", name, r, err)
+		}
+		m = newMailbox(name)
+		if err := s.Expire(m.ReceiptExpires); err != nil {
+			t.Errorf("%v: unwanted error: %v", name, err)
+		}
+		if _, err := s.Take(m.ID, now); !errors.Is(err, ErrMailboxNotFound) {
+			t.Errorf("%v: wanted mailbox to be removed when it expired, got %v", name, err)
+		}
+		if _, err := s.Receipt(m.ReceiptToken, now); !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("%v: wanted receipt to be removed when it expired, got %v", name, err)
+		}
+	}
+}
+
+func TestMailboxStoresFull(t *testing.T) {
+	fileStore, err := NewFileMailboxStore(t.TempDir(), 1)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	stores := map[string]MailboxStore{
+		"memory": NewMemoryMailboxStore(1),
+		"file":   fileStore,
+	}
+	expires := time.Now().Add(time.Minute)
+	for name, s := range stores {
+		a := Mailbox{
+			ID:             "AAAAAAAAAAAAAAAAAAAAAA",
+			ReceiptToken:   "BAAAAAAAAAAAAAAAAAAAAA",
+			Expires:        expires,
+			ReceiptExpires: expires,
+		}
+		if err := s.Put(a); err != nil {
+			t.Fatalf("%v: unwanted error: %v", name, err)
+		}
+		if _, err := s.Take(a.ID, time.Now()); err != nil {
+			t.Fatalf("%v: unwanted error: %v", name, err)
+		}
+		b := Mailbox{
+			ID:             "CAAAAAAAAAAAAAAAAAAAAA",
+			ReceiptToken:   "DAAAAAAAAAAAAAAAAAAAAA",
+			Expires:        expires,
+			ReceiptExpires: expires,
+		}
+		if err := s.Put(b); !errors.Is(err, ErrMailboxFull) {
+			t.Errorf("%v: wanted mailbox full error while receipt is kept, got %v", name, err)
+		}
+		if err := s.Expire(expires); err != nil {
+			t.Fatalf("%v: unwanted error: %v", name, err)
+		}
+		if err := s.Put(b); err != nil {
+			t.Errorf("%v: wanted mailbox to be put after receipt expired, got %v", name, err)
+		}
+	}
+}
+
+func TestFileMailboxStoreInvalidID(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileMailboxStore(filepath.Join(dir, "mailboxes"), 10)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	secret := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secret, []byte("secret"), 0600); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	ids := []string{
+		"../secret",
+		"",
+		"short",
+		"AAAAAAAAAAAAAAAAAAAAAA/",
+	}
+	validID := "AAAAAAAAAAAAAAAAAAAAAA"
+	for i, id := range ids {
+		m := Mailbox{
+			ID:             id,
+			ReceiptToken:   validID,
+			Expires:        time.Now().Add(time.Minute),
+			ReceiptExpires: time.Now().Add(time.Minute),
+		}
+		if err := s.Put(m); err == nil {
+			t.Errorf("test %v: wanted error putting invalid id", i)
+		}
+		m.ID, m.ReceiptToken = validID, id
+		if err := s.Put(m); err == nil {
+			t.Errorf("test %v: wanted error putting invalid receipt token", i)
+		}
+		if _, err := s.Take(id, time.Now()); !errors.Is(err, ErrMailboxNotFound) {
+			t.Errorf("test %v: wanted mailbox not found error, got %v", i, err)
+		}
+		if _, err := s.Receipt(id, time.Now()); !errors.Is(err, ErrReceiptNotFound) {
+			t.Errorf("test %v: wanted receipt not found error, got %v", i, err)
+		}
+	}
+	if _, err := os.Stat(secret); err != nil {
+		t.Errorf("wanted file outside of mailbox directory to be kept: %v", err)
+	}
+}
+
+func TestHandleMailbox(t *testing.T) {
+	cfg := Config{
+		Log:            log.New(ioutil.Discard, "test", log.LstdFlags),
+		Port:           8001,
+		ResourcesFS:    testResourcesFS,
+		MailboxStore:   NewMemoryMailboxStore(10),
+		MailboxMaxSize: 8,
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	h := s.server.Handler
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/mailbox", strings.NewReader(body))
+		h.ServeHTTP(w, r)
+		return w
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		h.ServeHTTP(w, r)
+		return w
+	}
+	w := post("cipher")
+	if want, got := http.StatusCreated, w.Code; want != got {
+		t.Fatalf("wanted status %v, got %v: %v", want, got, w.Body.String())
+	}
+	var m mailboxResponse
+	if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+		t.Fatalf("unwanted error decoding response: %v", err)
+	}
+	location := w.Header().Get("Location")
+	switch {
+	case !mailboxIDPattern.MatchString(m.ID):
+		t.Errorf("wanted random mailbox id, got %q", m.ID)
+	case location != "/mailbox/"+m.ID:
+		t.Errorf("wanted location of mailbox, got %q", location)
+	case !m.Expires.After(time.Now()):
+		t.Errorf("wanted mailbox to expire in the future, got %v", m.Expires)
+	case !mailboxIDPattern.MatchString(m.Receipt), m.Receipt == m.ID:
+		t.Errorf("wanted random receipt token, got %q", m.Receipt)
+	case m.Link != "/read/"+m.ID:
+		t.Errorf("wanted read link of mailbox, got %q", m.Link)
+	}
+	var receipt Receipt
+	w = get("/receipt/" + m.Receipt)
+	switch {
+	case w.Code != http.StatusOK:
+		t.Errorf("wanted ok receipt status, got %v", w.Code)
+	case json.NewDecoder(w.Body).Decode(&receipt) != nil:
+		t.Errorf("wanted receipt json")
+	case receipt.Fetched != nil:
+		t.Errorf("wanted receipt to not be fetched yet, got %v", receipt.Fetched)
+	}
+	w = get(location)
+	switch {
+	case w.Code != http.StatusOK:
+		t.Errorf("wanted ok status, got %v", w.Code)
+	case w.Body.String() != "cipher":
+		t.Errorf("wanted cipher text, got %q", w.Body.String())
+	case w.Header().Get("Cache-Control") != "no-store":
+		t.Errorf("wanted cipher text to not be cached")
+	}
+	receipt = Receipt{}
+	w = get("/receipt/" + m.Receipt)
+	switch {
+	case w.Code != http.StatusOK:
+		t.Errorf("wanted ok receipt status, got %v", w.Code)
+	case json.NewDecoder(w.Body).Decode(&receipt) != nil:
+		t.Errorf("wanted receipt json")
+	case receipt.Fetched == nil:
+		t.Errorf("wanted receipt to be fetched")
+	}
+	handleMailboxTests := []struct {
+		w    *httptest.ResponseRecorder
+		want int
+	}{
+		{get(location), http.StatusNotFound}, // already taken
+		{post("too much cipher text"), http.StatusRequestEntityTooLarge},
+		{post(""), http.StatusBadRequest},
+		{get("/mailbox"), http.StatusMethodNotAllowed},
+		{get("/mailbox/main.html"), http.StatusNotFound},
+		{get("/receipt/" + m.ID), http.StatusNotFound},
+		{get("/read/" + m.ID), http.StatusOK}, // the page reports the taken mailbox when it is fetched
+	}
+	for i, test := range handleMailboxTests {
+		if test.want != test.w.Code {
+			t.Errorf("test %v: wanted status %v, got %v", i, test.want, test.w.Code)
+		}
+	}
+}
+
+func TestServeReadLink(t *testing.T) {
+	cfg := Config{
+		Log:  log.New(ioutil.Discard, "test", log.LstdFlags),
+		Port: 8001,
+		ResourcesFS: &fstest.MapFS{
+			"resources/html/main.html": {
+				Data: []byte(`<input value="{{.MailboxID}}">`),
+			},
+			"resources/main.css": {},
+		},
+		MailboxStore: NewMemoryMailboxStore(10),
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	h := s.server.Handler
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/mailbox", strings.NewReader("<cipher>"))
+	h.ServeHTTP(w, r)
+	var m mailboxResponse
+	if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+		t.Fatalf("unwanted error decoding response: %v", err)
+	}
+	wantBody := `<input value="` + m.ID + `">`
+	for i := 0; i < 2; i++ { // link previews should not delete the mailbox
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", m.Link, nil)
+		h.ServeHTTP(w, r)
+		switch {
+		case w.Code != http.StatusOK:
+			t.Errorf("test %v: wanted status %v, got %v", i, http.StatusOK, w.Code)
+		case wantBody != w.Body.String():
+			t.Errorf("test %v: not equal\nwanted: %v\ngot:    %v", i, wantBody, w.Body.String())
+		case w.Header().Get("Cache-Control") != "no-store", w.Header().Get("Referrer-Policy") != "no-referrer":
+			t.Errorf("test %v: wanted read link to not be cached or sent as a referrer", i)
+		case w.Header().Get("Content-Type") != "text/html; charset=utf-8":
+			t.Errorf("test %v: wanted html, got %q", i, w.Header().Get("Content-Type"))
+		}
+	}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/mailbox/"+m.ID, nil)
+	h.ServeHTTP(w, r)
+	if want, got := "<cipher>", w.Body.String(); want != got {
+		t.Errorf("wanted mailbox to be fetched after read link was served: not equal\nwanted: %v\ngot:    %v", want, got)
+	}
+}
+
+func TestHandleMailboxDisabled(t *testing.T) {
+	cfg := Config{
+		Log:         log.New(ioutil.Discard, "test", log.LstdFlags),
+		Port:        8001,
+		ResourcesFS: testResourcesFS,
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/mailbox", strings.NewReader("cipher"))
+	s.server.Handler.ServeHTTP(w, r)
+	if want, got := http.StatusMethodNotAllowed, w.Code; want != got {
+		t.Errorf("wanted status %v when mailboxes are disabled, got %v", want, got)
+	}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/read/AAAAAAAAAAAAAAAAAAAAAA", nil)
+	s.server.Handler.ServeHTTP(w, r)
+	if want, got := http.StatusNotFound, w.Code; want != got {
+		t.Errorf("wanted status %v for read links when mailboxes are disabled, got %v", want, got)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// noncePlaceholder is replaced with the nonce of the response in the values of security headers.
+// Inline scripts and styles in templates can only run if they have the nonce.
+const noncePlaceholder = "{nonce}"
+
+// nonceContextKey is the key of the nonce of the response in the context of the request.
+type nonceContextKey struct{}
+
+// DefaultSecurityHeaders creates the headers that are sent with every response.
+// The content security policy only allows scripts and styles from the site or with the nonce of the response.
+// WebAssembly is allowed to be compiled, but Javascript is not allowed to be evaluated.
+// The site cannot be framed or used by other origins and does not send referrers.
+func DefaultSecurityHeaders() map[string]string {
+	csp := []string{
+		"default-src 'self'",
+		"script-src 'self' 'nonce-" + noncePlaceholder + "' 'wasm-unsafe-eval'",
+		"style-src 'self' 'nonce-" + noncePlaceholder + "'",
+		"img-src 'self' data: blob:",
+		"connect-src 'self'",
+		"worker-src 'self'",
+		"manifest-src 'self'",
+		"object-src 'none'",
+		"base-uri 'none'",
+		"frame-ancestors 'none'",
+		"form-action 'self'",
+	}
+	return map[string]string{
+		"Content-Security-Policy":      strings.Join(csp, "; "),
+		"X-Frame-Options":              "DENY",
+		"X-Content-Type-Options":       "nosniff",
+		"Referrer-Policy":              "no-referrer",
+		"Permissions-Policy":           "camera=(), geolocation=(), microphone=(), payment=(), usb=()",
+		"Cross-Origin-Opener-Policy":   "same-origin",
+		"Cross-Origin-Embedder-Policy": "require-corp",
+		"Cross-Origin-Resource-Policy": "same-origin",
+	}
+}
+
+// securityHeaders replaces the default security headers with the changes.
+// Headers with empty values are removed.
+func securityHeaders(changes map[string]string) map[string]string {
+	headers := make(map[string]string)
+	for name, value := range DefaultSecurityHeaders() {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	for name, value := range changes {
+		name = http.CanonicalHeaderKey(name)
+		switch {
+		case len(value) == 0:
+			delete(headers, name)
+		default:
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// withSecurityHeaders adds the headers to responses from the handler.
+// A new nonce is created for each request and added to its context for templates.
+func withSecurityHeaders(h http.Handler, headers map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce, err := newNonce()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for name, value := range headers {
+			w.Header().Set(name, strings.ReplaceAll(value, noncePlaceholder, nonce))
+		}
+		ctx := context.WithValue(r.Context(), nonceContextKey{}, nonce)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// newNonce creates a random value that is used once.
+// It is encoded without characters that templates escape in attributes.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("creating nonce: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// requestNonce is the nonce for the response to the request, or an empty string if it does not have one.
+func requestNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(nonceContextKey{}).(string)
+	return nonce
+}
@@ -2,8 +2,10 @@
 package server
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"html/template"
 	"io"
@@ -14,6 +16,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/buildinfo"
 )
 
 type (
@@ -24,6 +28,22 @@ type (
 		tmpl    *template.Template
 		Log     *log.Logger
 		BuildFS fs.FS
+		// mailboxStore holds cipher text posted to mailboxes, if the mailbox API is enabled.
+		mailboxStore   MailboxStore
+		mailboxMaxSize int64
+		mailboxTTL     time.Duration
+		// chat relays chat messages, if chat is enabled.
+		chat *chatHub
+		// redirectServer redirects http requests to the https server, if tls is enabled.
+		redirectServer *http.Server
+		// buildInfo describes how the server and its files were built.
+		buildInfo *buildinfo.Info
+		// assetDirs are searched in order for files that are served unchanged.
+		assetDirs []assetDir
+		// assets are the entity tags and compressed content of the files of the asset directories, by path.
+		assets map[string]asset
+		// version is added to the urls of files so they can be cached until the version changes.
+		version string
 	}
 
 	// Config contains fields which describe the server.
@@ -32,12 +52,37 @@ type (
 		Log *log.Logger
 		// Version is used to bust caches of files from older server versions.
 		Version string
-		// Port is the TCP port for server http requests.
+		// Port is the TCP port for server http requests, or https requests if tls is enabled.
 		Port int
+		// TLSCertFile and TLSKeyFile are the PEM files of the tls certificate and its private key.
+		// The server uses https if they are set.
+		TLSCertFile, TLSKeyFile string
+		// TLSSelfSigned creates a self-signed certificate for the names and addresses of this computer if the tls files do not exist.
+		// The certificate is saved to the tls files so it is reused.
+		TLSSelfSigned bool
+		// HTTPRedirectPort is the TCP port for http requests that are redirected to https when tls is enabled.
+		// Requests are not redirected if it is zero.
+		HTTPRedirectPort int
 		// ResourcesFS contains the files and templates that are served.
 		ResourcesFS fs.FS
 		// BuildFS contains binary/build files to be served.
 		BuildFS fs.FS
+		// MailboxStore holds cipher text posted to mailboxes.
+		// The mailbox API is disabled if it is nil.
+		MailboxStore MailboxStore
+		// MailboxMaxSize is the maximum number of bytes of cipher text in a mailbox.
+		// DefaultMailboxMaxSize is used if it is not positive.
+		MailboxMaxSize int64
+		// MailboxTTL is how long mailboxes are kept before they expire.
+		// DefaultMailboxTTL is used if it is not positive.
+		MailboxTTL time.Duration
+		// Chat enables the websockets that relay cipher text between the participants of chat rooms.
+		Chat bool
+		// Branding names, describes, and colors the site.
+		Branding Branding
+		// SecurityHeaders change the headers of DefaultSecurityHeaders that are sent with every response.
+		// Headers with empty values are not sent.  The {nonce} placeholder is replaced with the nonce of the response.
+		SecurityHeaders map[string]string
 	}
 
 	// wrappedResponseWriter wraps response writing with another writer.
@@ -54,33 +99,110 @@ func (cfg Config) NewServer() (*Server, error) {
 		return nil, fmt.Errorf("missing logger")
 	case cfg.Port <= 0:
 		return nil, fmt.Errorf("invalid port: %v", cfg.Port)
+	case cfg.HTTPRedirectPort < 0, cfg.HTTPRedirectPort == cfg.Port:
+		return nil, fmt.Errorf("invalid http redirect port: %v", cfg.HTTPRedirectPort)
+	case (len(cfg.TLSCertFile) == 0) != (len(cfg.TLSKeyFile) == 0):
+		return nil, fmt.Errorf("tls certificate and key files must both be set")
+	}
+	branding := cfg.Branding.withDefaults()
+	if err := branding.validate(); err != nil {
+		return nil, fmt.Errorf("invalid branding: %w", err)
 	}
 	version := strings.TrimSpace(cfg.Version)
-	data := map[string]string{
-		"Version":         version,
-		"Name":            "Sarah-OTP",
-		"ShortName":       "S-OTP",
-		"Description":     "a secure message-passing app",
-		"ThemeColor":      "purple",
-		"BackgroundColor": "white",
+	data := branding.templateData()
+	data["Version"] = version
+	if cfg.MailboxStore != nil {
+		data["Mailbox"] = "true"
+	}
+	if cfg.Chat {
+		data["Chat"] = "true"
+	}
+	if cfg.BuildFS != nil {
+		if err := addIntegrity(data, cfg.BuildFS); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MailboxMaxSize <= 0 {
+		cfg.MailboxMaxSize = DefaultMailboxMaxSize
+	}
+	if cfg.MailboxTTL <= 0 {
+		cfg.MailboxTTL = DefaultMailboxTTL
+	}
+	buildInfo, err := buildinfo.New(version, map[string]fs.FS{
+		"resources": cfg.ResourcesFS,
+		"build":     cfg.BuildFS,
+	})
+	if err != nil {
+		return nil, err
+	}
+	assetDirs := []assetDir{
+		{cfg.ResourcesFS, staticDir},
+		{cfg.BuildFS, buildDir},
+	}
+	assets, err := newAssets(assetDirs, buildInfo.Assets)
+	if err != nil {
+		return nil, err
 	}
 	serveMux := new(http.ServeMux)
+	handler := withSecurityHeaders(serveMux, securityHeaders(cfg.SecurityHeaders))
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: serveMux,
+		Handler: handler,
 	}
 	t, err := template.ParseFS(cfg.ResourcesFS, "resources/html/*.html", "resources/*.*")
 	if err != nil {
 		return nil, fmt.Errorf("parsing template filesystem: %v", err)
 	}
+	if err := parsePublicTemplates(t, cfg.ResourcesFS); err != nil {
+		return nil, err
+	}
+	if len(cfg.TLSCertFile) != 0 {
+		cert, err := loadCertificate(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSSelfSigned, localHosts(), time.Now())
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{*cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		server.Handler = withHSTS(handler)
+	}
+	var redirectServer *http.Server
+	if server.TLSConfig != nil && cfg.HTTPRedirectPort != 0 {
+		redirectServer = &http.Server{
+			Addr:    fmt.Sprintf(":%d", cfg.HTTPRedirectPort),
+			Handler: redirectToHTTPS(cfg.Port),
+		}
+	}
 	s := Server{
-		Data:    data,
-		server:  server,
-		tmpl:    t,
-		Log:     cfg.Log,
-		BuildFS: cfg.BuildFS,
+		Data:   data,
+		server: server,
+
+		redirectServer: redirectServer,
+		tmpl:           t,
+		Log:            cfg.Log,
+		BuildFS:        cfg.BuildFS,
+
+		mailboxStore:   cfg.MailboxStore,
+		mailboxMaxSize: cfg.MailboxMaxSize,
+		mailboxTTL:     cfg.MailboxTTL,
+
+		buildInfo: buildInfo,
+		assetDirs: assetDirs,
+		assets:    assets,
+		version:   version,
 	}
 	serveMux.HandleFunc("/", s.handle)
+	if s.mailboxStore != nil {
+		serveMux.HandleFunc(mailboxPath, s.handleMailbox)
+		serveMux.HandleFunc(mailboxPath+"/", s.handleMailbox)
+		serveMux.HandleFunc(receiptPath+"/", s.handleReceipt)
+	}
+	if cfg.Chat {
+		s.chat = newChatHub()
+		serveMux.HandleFunc(chatPath+"/", s.handleChat)
+		server.RegisterOnShutdown(s.chat.closeAll)
+	}
 	return &s, nil
 }
 
@@ -89,6 +211,9 @@ func (cfg Config) NewServer() (*Server, error) {
 func (s Server) Run(ctx context.Context) <-chan error {
 	errC := make(chan error, 2)
 	go s.runServer(ctx, errC)
+	if s.mailboxStore != nil {
+		go s.expireMailboxes(ctx)
+	}
 	return errC
 }
 
@@ -98,6 +223,11 @@ func (s Server) Stop(ctx context.Context) error {
 	stopDur := 5 * time.Second
 	ctx, cancelFunc := context.WithTimeout(ctx, stopDur)
 	defer cancelFunc()
+	if s.redirectServer != nil {
+		if err := s.redirectServer.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
 	if err := s.server.Shutdown(ctx); err != nil {
 		return err
 	}
@@ -106,8 +236,19 @@ func (s Server) Stop(ctx context.Context) error {
 }
 
 func (s Server) runServer(ctx context.Context, errC chan<- error) {
-	s.Log.Printf("starting http server at at http://127.0.0.1%v", s.server.Addr)
-	errC <- s.server.ListenAndServe()
+	if s.server.TLSConfig == nil {
+		s.Log.Printf("starting http server at at http://127.0.0.1%v", s.server.Addr)
+		errC <- s.server.ListenAndServe()
+		return
+	}
+	if s.redirectServer != nil {
+		go func() {
+			s.Log.Printf("redirecting http requests at http://127.0.0.1%v to https", s.redirectServer.Addr)
+			errC <- s.redirectServer.ListenAndServe()
+		}()
+	}
+	s.Log.Printf("starting https server at at https://127.0.0.1%v", s.server.Addr)
+	errC <- s.server.ListenAndServeTLS("", "")
 }
 
 // handle handles HTTP endpoints.
@@ -122,47 +263,92 @@ func (s Server) handle(w http.ResponseWriter, r *http.Request) {
 
 // handleGet calls handlers for GET endpoints.
 func (s Server) handleGet(w http.ResponseWriter, r *http.Request) {
-	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-		w2 := gzip.NewWriter(w)
-		defer w2.Close()
-		w = wrappedResponseWriter{
-			Writer:         w2,
-			ResponseWriter: w,
-		}
-		w.Header().Set("Content-Encoding", "gzip")
-	}
-	switch r.URL.Path {
-	case "/", "/serviceWorker.js", "/manifest.json", "/favicon.svg", "/network_check.html", "/robots.txt":
-		s.serveTemplate(w, r, r.URL.Path)
-	case "/wasm_exec.js", "/main.wasm":
-		http.ServeFileFS(w, r, s.BuildFS, "build"+r.URL.Path)
-	case "/favicon.ico":
-		// NOOP
+	switch path := r.URL.Path; {
+	case path == "/":
+		s.serveTemplate(w, r, path)
+	case path == buildinfo.Path:
+		s.serveBuildInfo(w)
+	case strings.HasPrefix(path, readPath+"/") && s.mailboxStore != nil:
+		s.serveReadLink(w, r)
 	default:
-		s.httpError(w, http.StatusNotFound)
+		s.serveAsset(w, r)
 	}
 }
 
 // serveTemplate servers the file from the data-driven template.
+// The main page is served at the root path and public templates are served at their paths.
 func (s Server) serveTemplate(w http.ResponseWriter, r *http.Request, name string) {
 	switch name {
 	case "/":
 		name = "main.html"
 	default:
-		name = name[1:]
+		name = publicTemplatePrefix + name[1:]
 	}
+	w.Header().Set("Cache-Control", s.cacheControl(r, name))
+	data := s.templateData(r)
+	s.serveTemplateData(w, r, name, http.StatusOK, data)
+}
+
+// serveTemplateData serves the named template, rendered with the data, with the status code.
+// Successful responses that can be stored have an ETag of the rendered template unless it has the nonce of the response.
+// Templates with the nonce are always rendered again so their content security policy allows the nonce.
+// The response is compressed with gzip if the client accepts it.
+func (s Server) serveTemplateData(w http.ResponseWriter, r *http.Request, name string, statusCode int, data any) {
 	t := s.tmpl.Lookup(name)
 	if t == nil {
 		err := fmt.Errorf("looking up file %v: not found", name)
 		s.handleError(w, err)
 		return
 	}
-	s.addMimeType(name, w)
-	if err := t.Execute(w, s.Data); err != nil {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
 		err = fmt.Errorf("rendering template %v: %v", name, err)
 		s.handleError(w, err)
 		return
 	}
+	body := buf.Bytes()
+	gzipped := acceptsGzip(r)
+	w.Header().Add("Vary", "Accept-Encoding")
+	nonce := requestNonce(r)
+	hasNonce := len(nonce) != 0 && bytes.Contains(body, []byte(nonce))
+	if statusCode == http.StatusOK && !hasNonce && !strings.Contains(w.Header().Get("Cache-Control"), "no-store") {
+		etag := contentETag(body)
+		if gzipped {
+			etag = gzipETag(etag)
+		}
+		w.Header().Set("ETag", etag)
+		if etagMatches(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	s.addMimeType(name, w)
+	if gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+		w2 := gzip.NewWriter(w)
+		defer w2.Close()
+		w = wrappedResponseWriter{
+			Writer:         w2,
+			ResponseWriter: w,
+		}
+	}
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		s.Log.Printf("writing template %v: %v", name, err)
+	}
+}
+
+// templateData creates a copy of the data of the server that can be changed for the response to the request.
+// The Nonce of the response allows inline scripts and styles.
+func (s Server) templateData(r *http.Request) map[string]any {
+	data := make(map[string]any)
+	if m, ok := s.Data.(map[string]any); ok {
+		for k, v := range m {
+			data[k] = v
+		}
+	}
+	data["Nonce"] = requestNonce(r)
+	return data
 }
 
 // httpError writes the error status code.
@@ -95,3 +95,175 @@ func TestAddMimeType(t *testing.T) {
 		}
 	}
 }
+
+func TestSecurityHeaders(t *testing.T) {
+	resourcesFS := &fstest.MapFS{
+		"resources/html/main.html": {Data: []byte(`<script nonce="{{.Nonce}}"></script>`)},
+		"resources/main.css":       {},
+	}
+	securityHeadersTests := []struct {
+		changes map[string]string
+		want    map[string]string
+	}{
+		{
+			want: map[string]string{
+				"X-Frame-Options":              "DENY",
+				"Referrer-Policy":              "no-referrer",
+				"Permissions-Policy":           "camera=(), geolocation=(), microphone=(), payment=(), usb=()",
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Embedder-Policy": "require-corp",
+				"Cross-Origin-Resource-Policy": "same-origin",
+			},
+		},
+		{
+			changes: map[string]string{
+				"x-frame-options":         "SAMEORIGIN",
+				"Permissions-Policy":      "",
+				"Content-Security-Policy": "script-src 'nonce-{nonce}'",
+			},
+			want: map[string]string{
+				"X-Frame-Options":    "SAMEORIGIN",
+				"Permissions-Policy": "",
+				"Referrer-Policy":    "no-referrer",
+			},
+		},
+	}
+	for i, test := range securityHeadersTests {
+		cfg := Config{
+			Log:             log.New(ioutil.Discard, "test", log.LstdFlags),
+			Port:            8001,
+			ResourcesFS:     resourcesFS,
+			SecurityHeaders: test.changes,
+		}
+		s, err := cfg.NewServer()
+		if err != nil {
+			t.Fatalf("test %v: unwanted error: %v", i, err)
+		}
+		nonces := make(map[string]struct{})
+		for j := 0; j < 2; j++ {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/", nil)
+			s.server.Handler.ServeHTTP(w, r)
+			for name, want := range test.want {
+				if got := w.Header().Get(name); want != got {
+					t.Errorf("test %v: wanted %v header %q, got %q", i, name, want, got)
+				}
+			}
+			csp := w.Header().Get("Content-Security-Policy")
+			body := w.Body.String()
+			nonce := strings.TrimSuffix(strings.TrimPrefix(body, `<script nonce="`), `"></script>`)
+			switch {
+			case len(nonce) == 0, nonce == body:
+				t.Errorf("test %v: wanted nonce in inline script, got %q", i, body)
+			case !strings.Contains(csp, "'nonce-"+nonce+"'"):
+				t.Errorf("test %v: wanted content security policy to allow scripts with nonce %q, got %q", i, nonce, csp)
+			case strings.Contains(csp, "unsafe-inline"):
+				t.Errorf("test %v: wanted content security policy to not allow all inline scripts, got %q", i, csp)
+			}
+			nonces[nonce] = struct{}{}
+		}
+		if len(nonces) != 2 {
+			t.Errorf("test %v: wanted a different nonce for each response, got %v", i, nonces)
+		}
+	}
+}
+
+func TestDefaultSecurityHeaders(t *testing.T) {
+	csp := DefaultSecurityHeaders()["Content-Security-Policy"]
+	wantDirectives := []string{
+		"default-src 'self'",
+		"script-src 'self' 'nonce-{nonce}' 'wasm-unsafe-eval'",
+		"object-src 'none'",
+		"frame-ancestors 'none'",
+	}
+	for i, want := range wantDirectives {
+		if !strings.Contains(csp, want) {
+			t.Errorf("test %v: wanted content security policy to contain %q, got %q", i, want, csp)
+		}
+	}
+}
+
+func TestServeAssets(t *testing.T) {
+	resourcesFS := &fstest.MapFS{
+		"resources/html/main.html":         {Data: []byte(`main {{.Name}}`)},
+		"resources/html/partial.html":      {Data: []byte(`partial`)},
+		"resources/main.css":               {Data: []byte(`main.css`)},
+		"resources/public/favicon.svg":     {Data: []byte(`<svg fill="{{.ThemeColor}}"></svg>`)},
+		"resources/public/icons/check.svg": {Data: []byte(`<svg>{{template "partial.html"}}</svg>`)},
+		"resources/static/robots.txt":      {Data: []byte(`User-agent: *`)},
+		"resources/static/print.css":       {Data: []byte(`body { color: black; }`)},
+		"resources/static/lang/en.json":    {Data: []byte(`{"hello": "hello"}`)},
+		"resources/static/lang/index.html": {Data: []byte(`index`)},
+		"resources/secret.txt":             {Data: []byte(`secret`)},
+		"secret.txt":                       {Data: []byte(`secret`)},
+	}
+	buildFS := fstest.MapFS{
+		"build/wasm_exec.js": {Data: []byte(`wasm_exec`)},
+		"build/main.wasm":    {Data: []byte("\x00asm")},
+	}
+	cfg := Config{
+		Log:         log.New(ioutil.Discard, "test", log.LstdFlags),
+		Port:        8001,
+		ResourcesFS: resourcesFS,
+		BuildFS:     buildFS,
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	serveAssetTests := []struct {
+		path            string
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{"/", 200, "text/html; charset=utf-8", "main Sarah-OTP"},
+		{"/favicon.svg", 200, "image/svg+xml", `<svg fill="purple"></svg>`},
+		{"/icons/check.svg", 200, "image/svg+xml", "<svg>partial</svg>"},
+		{"/robots.txt", 200, "text/plain; charset=utf-8", "User-agent: *"},
+		{"/print.css", 200, "text/css; charset=utf-8", "body { color: black; }"},
+		{"/lang/en.json", 200, "application/json", `{"hello": "hello"}`},
+		{"/lang/index.html", 200, "text/html; charset=utf-8", "index"},
+		{"/main.wasm", 200, "application/wasm", "\x00asm"},
+		{"/wasm_exec.js", 200, "text/javascript; charset=utf-8", "wasm_exec"},
+		{"/main.html", 404, "", ""},
+		{"/partial.html", 404, "", ""},
+		{"/main.css", 404, "", ""},
+		{"/secret.txt", 404, "", ""},
+		{"/favicon.ico", 404, "", ""},
+		{"/lang", 404, "", ""},
+		{"/lang/", 404, "", ""},
+		{"/icons", 404, "", ""},
+		{"/resources/static/robots.txt", 404, "", ""},
+		{"/static/robots.txt", 404, "", ""},
+		{"/build/main.wasm", 404, "", ""},
+		{"/../secret.txt", 404, "", ""},
+		{"/../../secret.txt", 404, "", ""},
+		{"/lang/../../secret.txt", 404, "", ""},
+		{"/lang/../robots.txt", 404, "", ""},
+		{"/./robots.txt", 404, "", ""},
+		{`/..\secret.txt`, 404, "", ""},
+		{"//robots.txt", 404, "", ""},
+	}
+	for i, test := range serveAssetTests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.URL.Path = test.path // not cleaned, unlike requests from the server mux
+		s.handleGet(w, r)
+		switch {
+		case test.wantCode != w.Code:
+			t.Errorf("test %v (%v): wanted status code %v, got %v", i, test.path, test.wantCode, w.Code)
+		case test.wantCode != 200:
+		case test.wantContentType != w.Header().Get("Content-Type"):
+			t.Errorf("test %v (%v): wanted content type %q, got %q", i, test.path, test.wantContentType, w.Header().Get("Content-Type"))
+		case test.wantBody != w.Body.String():
+			t.Errorf("test %v (%v): wanted body %q, got %q", i, test.path, test.wantBody, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/lang/%2e%2e/%2e%2e/secret.txt", nil)
+	s.server.Handler.ServeHTTP(w, r)
+	if w.Code == 200 || strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("wanted encoded traversal to not be served, got %v: %q", w.Code, w.Body.String())
+	}
+}
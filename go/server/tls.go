@@ -0,0 +1,176 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// selfSignedValidity is how long self-signed certificates are valid.
+	selfSignedValidity = 365 * 24 * time.Hour
+	// selfSignedOrganization is the organization of the subject of self-signed certificates, which identifies the certificates the server creates.
+	selfSignedOrganization = "Sarah-OTP self-signed"
+	// hstsMaxAge is how many seconds browsers should only use https for the site after visiting it.
+	hstsMaxAge = 2 * 365 * 24 * 60 * 60
+)
+
+// loadCertificate reads the certificate and private key from the PEM files.
+// If selfSigned is true and both files do not exist, a self-signed certificate for the hosts is created and written to the files.
+// A self-signed certificate that was created by the server is replaced when it expires.
+// Files that are only partly missing are not replaced, so a key is never overwritten without its certificate.
+func loadCertificate(certFile, keyFile string, selfSigned bool, hosts []string, now time.Time) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	switch {
+	case err == nil && selfSigned && expiredSelfSigned(cert, now):
+		// replace the certificate
+	case err == nil:
+		return &cert, nil
+	case !selfSigned || !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("loading tls certificate: %w", err)
+	case fileExists(certFile) || fileExists(keyFile):
+		return nil, fmt.Errorf("loading tls certificate: both the certificate and key files must be missing to create a self-signed certificate: %w", err)
+	}
+	certPEM, keyPEM, err := newSelfSignedCertificate(hosts, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		name string
+		data []byte
+		perm fs.FileMode
+	}{
+		{certFile, certPEM, 0644},
+		{keyFile, keyPEM, 0600},
+	} {
+		if err := os.MkdirAll(filepath.Dir(f.name), 0700); err != nil {
+			return nil, fmt.Errorf("creating tls directory: %w", err)
+		}
+		if err := os.WriteFile(f.name, f.data, f.perm); err != nil {
+			return nil, fmt.Errorf("writing self-signed tls file: %w", err)
+		}
+	}
+	cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("loading self-signed tls certificate: %w", err)
+	}
+	return &cert, nil
+}
+
+// expiredSelfSigned reports whether or not the certificate is a self-signed certificate created by the server that is no longer valid at the time.
+func expiredSelfSigned(cert tls.Certificate, now time.Time) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false
+	}
+	return now.After(leaf.NotAfter) &&
+		slices.Equal(leaf.Subject.Organization, []string{selfSignedOrganization}) &&
+		leaf.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature) == nil
+}
+
+// fileExists reports whether or not the named file might exist.
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+// newSelfSignedCertificate creates a PEM certificate and private key for the hosts, which can be names or IP addresses.
+// Browsers warn about self-signed certificates until they are trusted, but they allow https on local networks.
+func newSelfSignedCertificate(hosts []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating tls key: %w", err)
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating certificate serial number: %w", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{selfSignedOrganization}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding tls key: %w", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// localHosts lists the names and addresses this computer can be reached at, for self-signed certificates.
+func localHosts() []string {
+	hosts := []string{"localhost"}
+	if name, err := os.Hostname(); err == nil {
+		hosts = append(hosts, name)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return append(hosts, "127.0.0.1", "::1")
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok {
+			hosts = append(hosts, n.IP.String())
+		}
+	}
+	return hosts
+}
+
+// redirectToHTTPS redirects requests to the same host and path using https on the port.
+func redirectToHTTPS(httpsPort int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		switch {
+		case httpsPort != 443:
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		case strings.Contains(host, ":"): // IPv6
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}
+}
+
+// withHSTS tells browsers to only use https for the site for responses from the handler.
+func withHSTS(h http.Handler) http.Handler {
+	value := "max-age=" + strconv.Itoa(hstsMaxAge)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls", "cert.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	hosts := []string{"localhost", "192.168.1.2", "::1"}
+	if _, err := loadCertificate(certFile, keyFile, false, hosts, now); err == nil {
+		t.Errorf("wanted error loading missing certificate when self-signed certificates are not allowed")
+	}
+	cert, err := loadCertificate(certFile, keyFile, true, hosts, now)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	switch {
+	case len(leaf.DNSNames) != 1, leaf.DNSNames[0] != "localhost":
+		t.Errorf("wanted certificate for localhost, got %v", leaf.DNSNames)
+	case len(leaf.IPAddresses) != 2:
+		t.Errorf("wanted certificate for ip addresses, got %v", leaf.IPAddresses)
+	case !leaf.NotAfter.Equal(now.Add(selfSignedValidity)):
+		t.Errorf("wanted certificate to expire at %v, got %v", now.Add(selfSignedValidity), leaf.NotAfter)
+	case leaf.VerifyHostname("192.168.1.2") != nil:
+		t.Errorf("wanted certificate to be valid for lan address")
+	}
+	info, err := os.Stat(keyFile)
+	switch {
+	case err != nil:
+		t.Errorf("wanted key file to be saved: %v", err)
+	case info.Mode().Perm() != 0600:
+		t.Errorf("wanted key file to only be readable by its owner, got %v", info.Mode().Perm())
+	}
+	cert2, err := loadCertificate(certFile, keyFile, true, hosts, now.Add(time.Hour))
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case string(cert.Certificate[0]) != string(cert2.Certificate[0]):
+		t.Errorf("wanted saved self-signed certificate to be reused")
+	}
+	expired := now.Add(selfSignedValidity + time.Hour)
+	cert3, err := loadCertificate(certFile, keyFile, true, hosts, expired)
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case string(cert.Certificate[0]) == string(cert3.Certificate[0]):
+		t.Errorf("wanted expired self-signed certificate to be replaced")
+	}
+	cert4, err := loadCertificate(certFile, keyFile, false, hosts, expired.Add(selfSignedValidity+time.Hour))
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case string(cert3.Certificate[0]) != string(cert4.Certificate[0]):
+		t.Errorf("wanted expired certificate to be loaded when self-signed certificates are not allowed")
+	}
+	if err := os.Remove(certFile); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if _, err := loadCertificate(certFile, keyFile, true, hosts, now); err == nil {
+		t.Errorf("wanted error loading key without certificate instead of replacing the key")
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert3.Certificate[0]}), 0644); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY"}), 0600); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if _, err := loadCertificate(certFile, keyFile, true, hosts, now); err == nil {
+		t.Errorf("wanted error loading invalid key instead of replacing it")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	redirectTests := []struct {
+		httpsPort int
+		host      string
+		target    string
+		want      string
+	}{
+		{443, "example.com", "/", "https://example.com/"},
+		{443, "example.com:80", "/read/abc?x=1", "https://example.com/read/abc?x=1"},
+		{8443, "192.168.1.2:8080", "/main.wasm", "https://192.168.1.2:8443/main.wasm"},
+		{443, "[::1]:80", "/", "https://[::1]/"},
+		{8443, "[::1]", "/", "https://[::1]:8443/"},
+	}
+	for i, test := range redirectTests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", test.target, nil)
+		r.Host = test.host
+		redirectToHTTPS(test.httpsPort).ServeHTTP(w, r)
+		switch {
+		case w.Code != http.StatusMovedPermanently:
+			t.Errorf("test %v: wanted permanent redirect, got %v", i, w.Code)
+		case w.Header().Get("Location") != test.want:
+			t.Errorf("test %v: wanted redirect to %v, got %v", i, test.want, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestNewServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		Log:              log.New(ioutil.Discard, "test", log.LstdFlags),
+		Port:             8443,
+		ResourcesFS:      testResourcesFS,
+		TLSCertFile:      filepath.Join(dir, "cert.pem"),
+		TLSKeyFile:       filepath.Join(dir, "key.pem"),
+		TLSSelfSigned:    true,
+		HTTPRedirectPort: 8080,
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if s.server.TLSConfig == nil || s.redirectServer == nil {
+		t.Fatalf("wanted tls server and http redirect server")
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/robots.txt", nil)
+	s.server.Handler.ServeHTTP(w, r)
+	if want, got := "max-age=63072000", w.Header().Get("Strict-Transport-Security"); want != got {
+		t.Errorf("wanted hsts header %q, got %q", want, got)
+	}
+	invalidConfigs := []Config{
+		{TLSCertFile: cfg.TLSCertFile},
+		{TLSCertFile: cfg.TLSCertFile, TLSKeyFile: cfg.TLSKeyFile, HTTPRedirectPort: cfg.Port},
+		{TLSCertFile: filepath.Join(dir, "missing.pem"), TLSKeyFile: cfg.TLSKeyFile},
+	}
+	for i, c := range invalidConfigs {
+		c.Log, c.Port, c.ResourcesFS = cfg.Log, cfg.Port, cfg.ResourcesFS
+		if _, err := c.NewServer(); err == nil {
+			t.Errorf("test %v: wanted error", i)
+		}
+	}
+	cfg = Config{
+		Log:              cfg.Log,
+		Port:             8001,
+		ResourcesFS:      testResourcesFS,
+		HTTPRedirectPort: 8080,
+	}
+	s, err = cfg.NewServer()
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case s.server.TLSConfig != nil, s.redirectServer != nil:
+		t.Errorf("wanted http server without redirects when tls is not enabled")
+	}
+	w = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, r)
+	if got := w.Header().Get("Strict-Transport-Security"); len(got) != 0 {
+		t.Errorf("wanted no hsts header without tls, got %q", got)
+	}
+}
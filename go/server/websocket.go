@@ -0,0 +1,292 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The websocket protocol is described by RFC 6455.
+const (
+	// websocketGUID is appended to the key of the client to create the accept header of the handshake.
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// websocket opcodes
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xA
+	// websocket close status codes
+	closeNormal      = 1000
+	closeGoingAway   = 1001
+	closeProtocol    = 1002
+	closeUnsupported = 1003
+	closeTooBig      = 1009
+	// maxControlPayload is the maximum size of the payload of control frames.
+	maxControlPayload = 125
+	// websocketWriteTimeout limits how long writing to a slow peer can block.
+	websocketWriteTimeout = 10 * time.Second
+)
+
+var (
+	// errWebsocketClosed is returned when reading from a websocket that the peer closed.
+	errWebsocketClosed = errors.New("websocket closed")
+	// errWebsocketTooBig is returned when a message is larger than the read limit.
+	errWebsocketTooBig = errors.New("websocket message too big")
+)
+
+// websocketConn is the server side of a websocket connection.
+// Messages can be written from several goroutines, but should only be read from one.
+// Reads fail with os.ErrDeadlineExceeded if no frame is received within the idle timeout, when it is set.
+type websocketConn struct {
+	conn        net.Conn
+	r           *bufio.Reader
+	idleTimeout time.Duration
+	writeMu     sync.Mutex
+	closed      bool
+}
+
+// websocketAccept creates the accept header for the handshake of the key from the client.
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// upgradeWebsocket completes the opening handshake of a websocket request and takes over its connection.
+// Requests from other origins are rejected so other sites cannot use the websocket of a visitor.
+// An http error is written if the request cannot be upgraded.
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request) (*websocketConn, error) {
+	key := r.Header.Get("Sec-Websocket-Key")
+	switch {
+	case r.Method != "GET":
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("websocket method not GET: %v", r.Method)
+	case !headerContainsToken(r.Header, "Connection", "upgrade"), !headerContainsToken(r.Header, "Upgrade", "websocket"):
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("not a websocket upgrade request")
+	case r.Header.Get("Sec-Websocket-Version") != "13":
+		w.Header().Set("Sec-Websocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("unsupported websocket version: %q", r.Header.Get("Sec-Websocket-Version"))
+	case len(key) == 0:
+		http.Error(w, "missing websocket key", http.StatusBadRequest)
+		return nil, fmt.Errorf("missing websocket key")
+	case !sameOrigin(r):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return nil, fmt.Errorf("websocket origin not allowed: %q", r.Header.Get("Origin"))
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		err := fmt.Errorf("websocket connection cannot be hijacked")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("hijacking websocket connection: %w", err)
+	}
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("writing websocket handshake: %w", err)
+	}
+	c := websocketConn{
+		conn: conn,
+		r:    rw.Reader,
+	}
+	return &c, nil
+}
+
+// headerContainsToken reports whether the comma-separated values of the header contain the token, ignoring case.
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether the request has no origin or was sent from a page of the host it was sent to.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true // not from a browser
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// ReadMessage reads the next text or binary message, which can be split into several frames.
+// Pings are answered while waiting for the message.
+// When the peer closes the connection, the close is answered and errWebsocketClosed is returned.
+// Messages larger than the limit are rejected by closing the connection.
+func (c *websocketConn) ReadMessage(limit int) (byte, []byte, error) {
+	var opcode byte
+	var message []byte
+	for {
+		fin, op, payload, err := c.readFrame(limit - len(message))
+		switch {
+		case errors.Is(err, errWebsocketTooBig):
+			c.Close(closeTooBig)
+			return 0, nil, err
+		case err != nil:
+			return 0, nil, err
+		}
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.Close(closeNormal)
+			return 0, nil, errWebsocketClosed
+		case opText, opBinary:
+			if opcode != 0 {
+				c.Close(closeProtocol)
+				return 0, nil, fmt.Errorf("websocket message started before previous message finished")
+			}
+			opcode = op
+		case opContinuation:
+			if opcode == 0 {
+				c.Close(closeProtocol)
+				return 0, nil, fmt.Errorf("websocket continuation without message")
+			}
+		default:
+			c.Close(closeProtocol)
+			return 0, nil, fmt.Errorf("unknown websocket opcode: %#x", op)
+		}
+		message = append(message, payload...)
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// readFrame reads a frame from the client, which must be masked.
+// Frames with payloads larger than the limit are not read.
+// Each frame refreshes the read deadline of the connection, so only peers that stop sending frames time out.
+func (c *websocketConn) readFrame(limit int) (fin bool, opcode byte, payload []byte, err error) {
+	if c.idleTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+	}
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return false, 0, nil, fmt.Errorf("reading websocket frame header: %w", err)
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch {
+	case header[0]&0x70 != 0:
+		c.Close(closeProtocol)
+		return false, 0, nil, fmt.Errorf("websocket extensions not supported")
+	case !masked:
+		c.Close(closeProtocol)
+		return false, 0, nil, fmt.Errorf("websocket frame from client not masked")
+	case opcode >= opClose && (!fin || length > maxControlPayload):
+		c.Close(closeProtocol)
+		return false, 0, nil, fmt.Errorf("invalid websocket control frame")
+	}
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return false, 0, nil, fmt.Errorf("reading websocket frame length: %w", err)
+		}
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return false, 0, nil, fmt.Errorf("reading websocket frame length: %w", err)
+		}
+		length = binary.BigEndian.Uint64(b[:])
+	}
+	if opcode < opClose && length > uint64(max(limit, 0)) {
+		return false, 0, nil, fmt.Errorf("%w: frame of %d bytes", errWebsocketTooBig, length)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return false, 0, nil, fmt.Errorf("reading websocket frame mask: %w", err)
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, fmt.Errorf("reading websocket frame payload: %w", err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage writes the message in a single frame.
+func (c *websocketConn) WriteMessage(opcode byte, message []byte) error {
+	return c.writeFrame(opcode, message)
+}
+
+// writeFrame writes a final, unmasked frame, as servers do.
+func (c *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return errWebsocketClosed
+	}
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, payload...)
+	c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	if _, err := c.conn.Write(frame); err != nil {
+		return fmt.Errorf("writing websocket frame: %w", err)
+	}
+	return nil
+}
+
+// Close sends a close frame with the status code and closes the connection.
+// Closing a connection more than once does nothing.
+func (c *websocketConn) Close(code uint16) error {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	err := c.writeFrame(opClose, payload)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if err2 := c.conn.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWebsocketClient is the client side of a websocket connection for tests.
+type testWebsocketClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialTestWebsocket opens a websocket to the path of the test server, returning the status code of the handshake.
+func dialTestWebsocket(t *testing.T, s *httptest.Server, path string, headers map[string]string) (*testWebsocketClient, int) {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialing test server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, err := http.NewRequest("GET", s.URL+path, nil)
+	if err != nil {
+		t.Fatalf("creating request: %v", err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("writing handshake: %v", err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatalf("reading handshake: %v", err)
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		if want, got := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"); want != got {
+			t.Errorf("wanted accept header %v, got %v", want, got)
+		}
+	}
+	c := testWebsocketClient{
+		conn: conn,
+		r:    r,
+	}
+	return &c, resp.StatusCode
+}
+
+// write sends a masked frame, as clients do.
+func (c testWebsocketClient) write(t *testing.T, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("writing frame: %v", err)
+	}
+}
+
+// read receives an unmasked frame from the server.
+func (c testWebsocketClient) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		t.Fatalf("reading frame header: %v", err)
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var b [2]byte
+		io.ReadFull(c.r, b[:])
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		io.ReadFull(c.r, b[:])
+		length = binary.BigEndian.Uint64(b[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatalf("reading frame payload: %v", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+func TestWebsocketAccept(t *testing.T) {
+	// example from RFC 6455
+	if want, got := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); want != got {
+		t.Errorf("wanted %v, got %v", want, got)
+	}
+}
+
+func TestHeaderContainsToken(t *testing.T) {
+	headerContainsTokenTests := []struct {
+		values []string
+		want   bool
+	}{
+		{[]string{"Upgrade"}, true},
+		{[]string{"keep-alive, Upgrade"}, true},
+		{[]string{"keep-alive", "upgrade"}, true},
+		{[]string{"keep-alive"}, false},
+		{[]string{"upgraded"}, false},
+		{nil, false},
+	}
+	for i, test := range headerContainsTokenTests {
+		h := http.Header{"Connection": test.values}
+		if got := headerContainsToken(h, "Connection", "upgrade"); test.want != got {
+			t.Errorf("test %v: wanted %v, got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestHandleChat(t *testing.T) {
+	cfg := Config{
+		Log:         log.New(ioutil.Discard, "test", log.LstdFlags),
+		Port:        8001,
+		ResourcesFS: testResourcesFS,
+		Chat:        true,
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	ts := httptest.NewServer(s.server.Handler)
+	defer ts.Close()
+	alice, code := dialTestWebsocket(t, ts, "/chat/room1", nil)
+	if code != http.StatusSwitchingProtocols {
+		t.Fatalf("wanted switching protocols status, got %v", code)
+	}
+	bob, _ := dialTestWebsocket(t, ts, "/chat/room1", nil)
+	eve, _ := dialTestWebsocket(t, ts, "/chat/room2", nil)
+	// wait for bob to join by having him send a message to alice
+	bob.write(t, true, opText, []byte("joined"))
+	if _, got := alice.read(t); string(got) != "joined" {
+		t.Fatalf("wanted join message, got %q", got)
+	}
+	alice.write(t, false, opText, []byte("cipher "))
+	alice.write(t, true, opPing, []byte("ping"))
+	alice.write(t, true, opContinuation, []byte("text"))
+	if op, got := alice.read(t); op != opPong || string(got) != "ping" {
+		t.Errorf("wanted pong, got %v: %q", op, got)
+	}
+	if op, got := bob.read(t); op != opText || string(got) != "cipher text" {
+		t.Errorf("wanted relayed message, got %v: %q", op, got)
+	}
+	large := bytes.Repeat([]byte("a"), 70000)
+	bob.write(t, true, opText, large[:maxChatMessageSize])
+	if _, got := alice.read(t); len(got) != maxChatMessageSize {
+		t.Errorf("wanted large message to be relayed, got %v bytes", len(got))
+	}
+	bob.write(t, true, opText, large)
+	op, got := bob.read(t)
+	if op != opClose || binary.BigEndian.Uint16(got) != closeTooBig {
+		t.Errorf("wanted close because message is too big, got %v: %v", op, got)
+	}
+	alice.write(t, true, opClose, nil)
+	if op, _ := alice.read(t); op != opClose {
+		t.Errorf("wanted close to be answered, got %v", op)
+	}
+	eve.conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := eve.r.ReadByte(); err == nil {
+		t.Errorf("wanted messages to not be relayed to other rooms")
+	}
+}
+
+func TestHandleChatHandshake(t *testing.T) {
+	cfg := Config{
+		Log:         log.New(ioutil.Discard, "test", log.LstdFlags),
+		Port:        8001,
+		ResourcesFS: testResourcesFS,
+		Chat:        true,
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	ts := httptest.NewServer(s.server.Handler)
+	defer ts.Close()
+	handshakeTests := []struct {
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"/chat/room", nil, http.StatusSwitchingProtocols},
+		{"/chat/room", map[string]string{"Origin": ts.URL}, http.StatusSwitchingProtocols},
+		{"/chat/room", map[string]string{"Origin": "https://example.com"}, http.StatusForbidden},
+		{"/chat/room", map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"/chat/room", map[string]string{"Upgrade": "h2c"}, http.StatusUpgradeRequired},
+		{"/chat/room", map[string]string{"Sec-WebSocket-Key": ""}, http.StatusBadRequest},
+		{"/chat/" + strings.Repeat("a", 65), nil, http.StatusNotFound},
+		{"/chat/room%2F..", nil, http.StatusNotFound},
+	}
+	for i, test := range handshakeTests {
+		_, got := dialTestWebsocket(t, ts, test.path, test.headers)
+		if test.want != got {
+			t.Errorf("test %v: wanted status %v, got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestChatHubRoomSize(t *testing.T) {
+	h := newChatHub()
+	for i := 0; i < maxChatRoomSize; i++ {
+		if err := h.join("room", new(websocketConn)); err != nil {
+			t.Fatalf("participant %v: unwanted error: %v", i, err)
+		}
+	}
+	switch {
+	case h.hasRoom("room"):
+		t.Errorf("wanted full room to not have room")
+	case h.join("room", new(websocketConn)) == nil:
+		t.Errorf("wanted error joining full room")
+	case !h.hasRoom("other"):
+		t.Errorf("wanted other room to have room")
+	}
+}
+
+func TestHandleChatIdle(t *testing.T) {
+	cfg := Config{
+		Log:         log.New(ioutil.Discard, "test", log.LstdFlags),
+		Port:        8001,
+		ResourcesFS: testResourcesFS,
+		Chat:        true,
+	}
+	s, err := cfg.NewServer()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	s.chat.idleTimeout = 100 * time.Millisecond
+	ts := httptest.NewServer(s.server.Handler)
+	defer ts.Close()
+	alice, _ := dialTestWebsocket(t, ts, "/chat/room", nil)
+	time.Sleep(60 * time.Millisecond)
+	alice.write(t, true, opPing, []byte("ping"))
+	if op, _ := alice.read(t); op != opPong {
+		t.Fatalf("wanted pong, got %v", op)
+	}
+	time.Sleep(60 * time.Millisecond)
+	alice.write(t, true, opPing, []byte("ping"))
+	if op, _ := alice.read(t); op != opPong {
+		t.Fatalf("wanted frames to keep participant from being idle, got %v", op)
+	}
+	start := time.Now()
+	op, got := alice.read(t)
+	switch {
+	case op != opClose || binary.BigEndian.Uint16(got) != closeGoingAway:
+		t.Errorf("wanted idle participant to be closed, got %v: %v", op, got)
+	case time.Since(start) < 50*time.Millisecond:
+		t.Errorf("wanted participant to be closed after idle timeout, got %v", time.Since(start))
+	}
+	rooms := func() int {
+		s.chat.mu.Lock()
+		defer s.chat.mu.Unlock()
+		return len(s.chat.rooms)
+	}
+	for i := 0; rooms() != 0; i++ { // wait for the participant to leave
+		if i == 100 {
+			t.Fatalf("wanted idle participant to leave room")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,133 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"syscall/js"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+var (
+	// encryptAttachments are the files to encrypt with the message.
+	encryptAttachments []otp.Attachment
+	// decryptedAttachments are the files of the last decrypted message.
+	decryptedAttachments []otp.Attachment
+)
+
+// initAttachments registers functions to read attachments to encrypt and download decrypted attachments.
+func initAttachments(ctx context.Context, wg *sync.WaitGroup) {
+	attachmentsChangeJsFunc := NewJsAsyncEventFunc(readEncryptAttachments)
+	QuerySelector("#encrypt-attachments").Call("addEventListener", "change", attachmentsChangeJsFunc)
+	decryptedAttachmentsClickJsFunc := NewJsEventFunc(downloadDecryptedAttachment)
+	QuerySelector(".decrypted-attachments").Call("addEventListener", "click", decryptedAttachmentsClickJsFunc)
+	jsFuncs := map[string]js.Func{
+		"#encrypt-attachments_change":  attachmentsChangeJsFunc,
+		".decrypted-attachments_click": decryptedAttachmentsClickJsFunc,
+	}
+	wg.Add(1)
+	go ReleaseJsFuncsOnDone(ctx, wg, jsFuncs)
+}
+
+// readEncryptAttachments reads the selected files to encrypt with the message.
+func readEncryptAttachments(event js.Value) {
+	SetButtonDisabled("#encrypt-submit", true)
+	defer updateCapacity()
+	files := QuerySelector("#encrypt-attachments").Get("files")
+	attachments := make([]otp.Attachment, 0, files.Length())
+	for i := 0; i < files.Length(); i++ {
+		file := files.Index(i)
+		name := file.Get("name").String()
+		buffer, err := Await(file.Call("arrayBuffer"))
+		if err != nil {
+			encryptAttachments = nil
+			logError("could not read attachment " + name + ": " + err.Error())
+			return
+		}
+		global := js.Global()
+		data := global.Get("Uint8Array").New(buffer)
+		a := otp.Attachment{
+			Name: name,
+			Type: file.Get("type").String(),
+			Data: goBytes(data),
+		}
+		attachments = append(attachments, a)
+	}
+	encryptAttachments = attachments
+}
+
+// messageContainer creates a container for the message and attachments.
+// The container is nil if there are no attachments, so the message can be encrypted by itself.
+func messageContainer(message string) *otp.Container {
+	if len(encryptAttachments) == 0 {
+		return nil
+	}
+	c := otp.Container{
+		Body:        message,
+		Attachments: encryptAttachments,
+	}
+	return &c
+}
+
+// encrypt encrypts the message and attachments with the options.
+func encrypt(opts otp.Options, message string, key *otp.Key) ([]byte, error) {
+	if c := messageContainer(message); c != nil {
+		return key.EncryptContainer(opts, *c)
+	}
+	m := []byte(message)
+	defer clear(m)
+	return key.Encrypt(opts, m)
+}
+
+// padLength is the number of key bytes that are used to encrypt the message and attachments with the options.
+func padLength(opts otp.Options, message string) (int, error) {
+	if c := messageContainer(message); c != nil {
+		return opts.ContainerPadLength(*c)
+	}
+	return opts.PadLength(message)
+}
+
+// showDecryptedAttachments lists the attachments with buttons to download them.
+func showDecryptedAttachments(attachments []otp.Attachment) {
+	decryptedAttachments = attachments
+	list := QuerySelector(".decrypted-attachments")
+	list.Set("innerHTML", "")
+	for i, a := range attachments {
+		clone := CloneElement(".decrypted-attachment")
+		item := clone.Get("children").Index(0)
+		item.Get("dataset").Set("index", i)
+		for query, text := range map[string]string{
+			".name": a.Name,
+			".type": a.Type,
+			".size": strconv.Itoa(len(a.Data)) + " bytes",
+		} {
+			element := item.Call("querySelector", query)
+			element.Set("textContent", text)
+		}
+		list.Call("appendChild", item)
+	}
+}
+
+// downloadDecryptedAttachment is executed when the user clicks the download button of a decrypted attachment.
+func downloadDecryptedAttachment(event js.Value) {
+	target := event.Get("target")
+	button := target.Call("closest", "button")
+	if !button.Truthy() {
+		return
+	}
+	item := button.Call("closest", "li")
+	i, err := strconv.Atoi(item.Get("dataset").Get("index").String())
+	if err != nil || i < 0 || i >= len(decryptedAttachments) {
+		logError("unknown attachment")
+		return
+	}
+	a := decryptedAttachments[i]
+	fileType := a.Type
+	if len(fileType) == 0 {
+		fileType = "application/octet-stream"
+	}
+	saveFile(a.Name, fileType, jsBytes(a.Data))
+}
@@ -0,0 +1,315 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+// errChatGroupKey describes why keys shared by two users cannot chat.
+// Both users would encrypt with the next unused bytes of the same key, so lines sent at the same moment would use the same bytes.
+var errChatGroupKey = errors.New("only group keys can chat, so each participant sends with a separate region of the key: create a group key to chat")
+
+var (
+	// chatKey encrypts and decrypts chat messages.  It is destroyed when it is replaced.
+	chatKey *otp.Key
+	// chatOpts are the sender and the next unused offset of the chat key.
+	chatOpts otp.Options
+	// chatVaultID is the id of the vault entry of the chat key, which records the used part of the key.
+	chatVaultID string
+	// chatSocket is the websocket of the joined chat room, or undefined if no room is joined.
+	chatSocket = js.Undefined()
+	// chatSocketFuncs handle the events of the websocket, by event type, until it closes.
+	chatSocketFuncs = make(map[string]js.Func)
+)
+
+// initChat registers the chat javascript functions if the server relays chat messages.
+func initChat(ctx context.Context, wg *sync.WaitGroup) {
+	if !QuerySelector("#chat-key").Truthy() {
+		return
+	}
+	chatFuncs := map[string]js.Func{
+		"join":  NewJsEventFunc(joinChat),
+		"send":  NewJsAsyncEventFunc(sendChat),
+		"leave": NewJsEventFunc(leaveChat),
+	}
+	RegisterFuncs(ctx, wg, "chat", chatFuncs)
+	jsFuncs := make(map[string]js.Func, 6)
+	addPemInput(jsFuncs, "#chat-key", "#chat-join", false, true, readChatKey)
+	updateChatCapacityJsFunc := NewJsFunc(updateChatCapacity)
+	QuerySelector("#chat-message").Call("addEventListener", "input", updateChatCapacityJsFunc)
+	jsFuncs["#chat-capacity_update"] = updateChatCapacityJsFunc
+	wg.Add(1)
+	go ReleaseJsFuncsOnDone(ctx, wg, jsFuncs)
+}
+
+// readChatKey is executed when the key to chat with is read.
+func readChatKey(text []byte) {
+	if err := setKey(&chatKey, text); err != nil {
+		logError(describeFailure("could not read key", err))
+	}
+	updateChatCapacity()
+}
+
+// joinChat is executed when the user joins a chat room.
+// The room is named by the fingerprint of the key if it is not specified, so users with the same key meet in the same room.
+// Only group keys in the unlocked vault can chat, so each participant sends with a separate region of the key and the used part of the key is recorded.
+func joinChat(event js.Value) {
+	if chatKey == nil {
+		logError("could not join chat: choose a key first")
+		return
+	}
+	offset, err := strconv.Atoi(Value("#chat-offset"))
+	if err != nil {
+		logError("could not join chat: converting offset to number: " + err.Error())
+		return
+	}
+	sender := strings.TrimSpace(Value("#chat-sender"))
+	if len(sender) == 0 {
+		logError("could not join chat: enter your sender in the group key")
+		return
+	}
+	if _, err := chatKey.Capacity(otp.Options{Sender: sender}); errors.Is(err, otp.ErrNotGroupKey) {
+		logError("could not join chat: " + errChatGroupKey.Error())
+		return
+	}
+	e, ok := findVaultEntry(chatKey, sender)
+	if !ok {
+		logError("could not join chat: add the key to the vault and unlock it so the used part of the key is recorded")
+		return
+	}
+	chatOpts = otp.Options{
+		Sender: sender,
+		Offset: max(offset, e.Offset),
+	}
+	if _, err := chatKey.Capacity(chatOpts); err != nil {
+		logError(describeFailure("could not join chat", err))
+		return
+	}
+	chatVaultID = e.ID
+	SetValue("#chat-offset", strconv.Itoa(chatOpts.Offset))
+	room := strings.TrimSpace(Value("#chat-room"))
+	if len(room) == 0 {
+		room = chatKey.Fingerprint()
+	}
+	closeChatSocket()
+	global := js.Global()
+	location := global.Get("location")
+	scheme := "ws:"
+	if location.Get("protocol").String() == "https:" {
+		scheme = "wss:"
+	}
+	socketURL := scheme + "//" + location.Get("host").String() + "/chat/" + url.PathEscape(room)
+	socket := global.Get("WebSocket").New(socketURL)
+	handlers := map[string]func(event js.Value){
+		"open": func(event js.Value) {
+			logInfo("joined chat room " + room)
+			SetButtonDisabled("#chat-join", true)
+			updateChatCapacity()
+		},
+		"message": func(event js.Value) {
+			receiveChat(event.Get("data").String())
+		},
+		"error": func(event js.Value) {
+			logError("chat connection error")
+		},
+		"close": func(event js.Value) {
+			logInfo("left chat room " + room)
+			closeChatSocket()
+		},
+	}
+	for name, fn := range handlers {
+		f := NewJsEventFunc(fn)
+		socket.Call("addEventListener", name, f)
+		chatSocketFuncs[name] = f
+	}
+	chatSocket = socket
+}
+
+// leaveChat is executed when the user leaves the chat room.
+func leaveChat(event js.Value) {
+	if chatSocket.Truthy() {
+		logInfo("left chat room")
+	}
+	closeChatSocket()
+}
+
+// closeChatSocket closes the websocket of the chat room, if any, and releases its event functions.
+func closeChatSocket() {
+	if chatSocket.Truthy() {
+		for name, f := range chatSocketFuncs {
+			chatSocket.Call("removeEventListener", name, f)
+			f.Release()
+		}
+		chatSocket.Call("close")
+	}
+	chatSocket = js.Undefined()
+	clear(chatSocketFuncs)
+	SetButtonDisabled("#chat-join", false)
+	SetButtonDisabled("#chat-send", true)
+}
+
+// sendChat is executed when the user sends a chat message.
+// The message is encrypted with the next unused bytes of the key, which are not used again.
+// The used part of the key is saved in the vault before the message is sent, so the message is not sent if the vault might reuse the bytes.
+func sendChat(event js.Value) {
+	if !chatSocket.Truthy() || chatKey == nil {
+		logError("could not send chat message: join a chat room first")
+		return
+	}
+	message := Value("#chat-message")
+	if len(message) == 0 {
+		return
+	}
+	vaultMu.Lock()
+	e, ok := vaultEntries[chatVaultID]
+	vaultMu.Unlock()
+	if !ok {
+		logError("could not send chat message: unlock the vault with the chat key so the used part of the key is recorded")
+		return
+	}
+	opts := chatOpts
+	opts.Offset = max(opts.Offset, e.Offset)
+	cipher, err := chatKey.Encrypt(opts, []byte(message))
+	if err != nil {
+		logError(describeFailure("could not encrypt chat message", err))
+		return
+	}
+	r, err := otp.CipherRange(string(cipher))
+	if err != nil {
+		logError("could not determine used part of key: " + err.Error())
+		return
+	}
+	e, ok = recordVaultEntry(chatVaultID, opts.Sender, r.End)
+	if !ok {
+		logError("could not send chat message: the vault was locked")
+		return
+	}
+	if err := saveVaultEntry(e); err != nil {
+		logError("could not send chat message: recording used part of vault key: " + err.Error())
+		return
+	}
+	if !chatSocket.Truthy() {
+		logError("could not send chat message: the chat room was left")
+		return
+	}
+	chatSocket.Call("send", string(cipher))
+	SetValue("#chat-message", "")
+	showChatMessage("me", message)
+	recordMessage(true, message, chatKey, string(cipher))
+	advanceChatOffset(r.End)
+}
+
+// receiveChat decrypts and shows a chat message from another participant.
+// Messages from the same region of the key advance the offset past the bytes they used so the bytes are not used again.
+func receiveChat(cipher string) {
+	if chatKey == nil {
+		return
+	}
+	message, err := chatKey.Decrypt([]byte(cipher))
+	if err != nil {
+		logError(describeFailure("could not decrypt chat message", err))
+		return
+	}
+	defer clear(message)
+	opts, err := otp.ParseOptions(cipher)
+	if err != nil {
+		logError("could not read chat message options: " + err.Error())
+		return
+	}
+	from := "partner"
+	if len(opts.Sender) != 0 {
+		from = opts.Sender
+	}
+	showChatMessage(from, string(message))
+	recordMessage(false, string(message), chatKey, cipher)
+	if opts.Sender != chatOpts.Sender {
+		return
+	}
+	r, err := otp.CipherRange(cipher)
+	if err != nil {
+		logError("could not determine used part of key: " + err.Error())
+		return
+	}
+	if r.Start < chatOpts.Offset {
+		logError("part of the key was used by more than one message, so messages that used it might be read by others")
+	}
+	consumeVaultEntry(chatVaultID, chatOpts.Sender, r.End)
+	advanceChatOffset(max(r.End, chatOpts.Offset))
+}
+
+// advanceChatOffset moves the offset of the chat key, stopping the chat if no more of the key can be used.
+func advanceChatOffset(offset int) {
+	chatOpts.Offset = offset
+	SetValue("#chat-offset", strconv.Itoa(offset))
+	if n, err := chatKey.Capacity(chatOpts); err == nil && n <= 0 {
+		logError("the chat key is used up, so the chat has stopped")
+		closeChatSocket()
+	}
+	updateChatCapacity()
+}
+
+// updateChatCapacity shows how many bytes of the key are left and whether or not the message fits in them.
+// Sending is disabled when no chat room is joined or when the message does not fit.
+func updateChatCapacity() {
+	text, ok := chatCapacity(Value("#chat-message"))
+	element := QuerySelector("#chat-capacity")
+	element.Set("textContent", text)
+	element.Get("classList").Call("toggle", "error", !ok)
+	SetButtonDisabled("#chat-send", !ok || !chatSocket.Truthy())
+}
+
+// chatCapacity describes how much of the chat key is left and whether or not the message fits in it.
+func chatCapacity(message string) (string, bool) {
+	if chatKey == nil {
+		return "", false
+	}
+	opts := chatOpts
+	if !chatSocket.Truthy() {
+		opts.Sender = strings.TrimSpace(Value("#chat-sender"))
+		if offset, err := strconv.Atoi(Value("#chat-offset")); err == nil {
+			opts.Offset = offset
+		}
+	}
+	n, err := chatKey.Capacity(opts)
+	switch {
+	case errors.Is(err, otp.ErrNotGroupKey):
+		return errChatGroupKey.Error(), false
+	case err != nil:
+		return describeError(err), false
+	}
+	m, err := opts.PadLength(message)
+	if err != nil {
+		return describeError(err), false
+	}
+	text := strconv.Itoa(n) + " bytes of the key left"
+	if m > n {
+		return text + ", but the message needs " + strconv.Itoa(m), false
+	}
+	return text, n > 0
+}
+
+// showChatMessage adds the message to the end of the chat thread.
+func showChatMessage(from, text string) {
+	clone := CloneElement(".chat-message")
+	item := clone.Get("children").Index(0)
+	for query, value := range map[string]string{
+		".time": time.Now().Format("15:04:05"),
+		".from": from,
+		".text": text,
+	} {
+		item.Call("querySelector", query).Set("textContent", value)
+	}
+	thread := QuerySelector(".chat-thread")
+	thread.Call("appendChild", clone)
+	item.Call("scrollIntoView")
+}
@@ -80,6 +80,19 @@ func SetChecked(query string, checked bool) {
 	element.Set("checked", checked)
 }
 
+// Checked gets the checked property of the element.
+func Checked(query string) bool {
+	element := QuerySelector(query)
+	checked := element.Get("checked")
+	return checked.Bool()
+}
+
+// SetText sets the text content of the element.
+func SetText(query, text string) {
+	element := QuerySelector(query)
+	element.Set("textContent", text)
+}
+
 // SetButtonDisabled sets the disable property of the button element.
 func SetButtonDisabled(query string, disabled bool) {
 	element := QuerySelector(query)
@@ -94,6 +107,54 @@ func CloneElement(query string) js.Value {
 	return clone
 }
 
+// OnSettled calls the function after the promise is settled.
+// The error is nil if the promise is fulfilled.
+func OnSettled(promise js.Value, fn func(err error)) {
+	var onFulfilled, onRejected js.Func
+	release := func() {
+		onFulfilled.Release()
+		onRejected.Release()
+	}
+	onFulfilled = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		defer AlertOnPanic()
+		release()
+		fn(nil)
+		return nil
+	})
+	onRejected = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		defer AlertOnPanic()
+		release()
+		reason := args[0]
+		fn(errors.New(reason.Call("toString").String()))
+		return nil
+	})
+	promise.Call("then", onFulfilled, onRejected)
+}
+
+// Await waits for the promise to settle, returning its value if it is fulfilled.
+// This must be called on a separate goroutine because it waits for javascript callbacks.
+func Await(promise js.Value) (js.Value, error) {
+	type result struct {
+		value js.Value
+		err   error
+	}
+	done := make(chan result, 1)
+	onFulfilled := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done <- result{value: args[0]}
+		return nil
+	})
+	defer onFulfilled.Release()
+	onRejected := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		reason := args[0]
+		done <- result{err: errors.New(reason.Call("toString").String())}
+		return nil
+	})
+	defer onRejected.Release()
+	promise.Call("then", onFulfilled, onRejected)
+	r := <-done // BLOCKING
+	return r.value, r.err
+}
+
 // FormatTime formats a date/time to HH:MM:SS.
 func FormatTime(utcSeconds int64) string {
 	t := time.Unix(utcSeconds, 0) // uses local timezone
@@ -0,0 +1,147 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"syscall/js"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+// defaultLanguage is the language of error descriptions when the browser's language is not known.
+const defaultLanguage = "en"
+
+// errorDescription explains how to fix an error from the otp package.
+type errorDescription struct {
+	err         error
+	description string
+}
+
+// errorDescriptions explain how to fix errors from the otp package, by language.
+// Errors are described by the first description of an error that they match, so more specific errors come first.
+// Descriptions of length errors replace {length}, {capacity}, and {over} with the numbers of key bytes.
+var errorDescriptions = map[string][]errorDescription{
+	"en": {
+		{otp.ErrWrongPassphrase, "wrong passphrase: check it and try again"},
+		{otp.ErrProtectedKey, "the key is protected by a passphrase: enter the passphrase to use it"},
+		{otp.ErrKeyReused, "that part of the key was already used: encrypt the message again at the next offset or use a new key"},
+		{otp.ErrDestroyedKey, "the key was cleared from memory: read it again"},
+		{otp.ErrKeyNotFound, "the key is not in the vault: add it to the vault or read it again"},
+		{otp.ErrDuplicateKey, "the key is already in the vault: use the key that is there"},
+		{otp.ErrMessageTooLong, "the message needs {length} key bytes, but only {capacity} remain: shorten it by {over} bytes, compress it, or use a larger key"},
+		{otp.ErrCipherTooLong, "the cipher text needs {length} key bytes, but the key only has {capacity}: make sure it is the key the message was encrypted with"},
+		{otp.ErrUnknownMember, "the sender is not a member of the group key: check the spelling of the sender"},
+		{otp.ErrNotGroupKey, "the key is not a group key: clear the sender or use a group key"},
+		{otp.ErrInvalidOffset, "the offset is outside of the key: use a smaller offset or a new key"},
+		{otp.ErrKeyTooSmall, "the key size must be at least one byte"},
+		{otp.ErrKeyTooLarge, "the key size is too large: use at most " + strconv.Itoa(otp.MaxKeyLength) + " bytes"},
+		{otp.ErrInvalidBlock, "a key or cipher text is damaged: copy it again, including the BEGIN and END lines"},
+		{otp.ErrMultipleBlocks, "more than one key or cipher text found: use only one of them"},
+		{otp.ErrLeadingData, "extra text found before the BEGIN line: remove everything before it"},
+		{otp.ErrTrailingData, "extra text found after the END line: remove everything after it"},
+		{otp.ErrNoPEM, "no key or cipher text found: paste the whole file, including the BEGIN and END lines"},
+	},
+	"es": {
+		{otp.ErrWrongPassphrase, "frase de contraseña incorrecta: revísela e inténtelo de nuevo"},
+		{otp.ErrProtectedKey, "la clave está protegida con una frase de contraseña: introdúzcala para usarla"},
+		{otp.ErrKeyReused, "esa parte de la clave ya se usó: cifre el mensaje de nuevo en el siguiente desplazamiento o use una clave nueva"},
+		{otp.ErrDestroyedKey, "la clave se borró de la memoria: léala de nuevo"},
+		{otp.ErrKeyNotFound, "la clave no está en la bóveda: agréguela a la bóveda o léala de nuevo"},
+		{otp.ErrDuplicateKey, "la clave ya está en la bóveda: use la clave que está allí"},
+		{otp.ErrMessageTooLong, "el mensaje necesita {length} bytes de la clave, pero solo quedan {capacity}: acórtelo {over} bytes, comprímalo o use una clave más grande"},
+		{otp.ErrCipherTooLong, "el texto cifrado necesita {length} bytes de la clave, pero la clave solo tiene {capacity}: asegúrese de usar la clave con la que se cifró el mensaje"},
+		{otp.ErrUnknownMember, "el remitente no es miembro de la clave de grupo: revise cómo está escrito"},
+		{otp.ErrNotGroupKey, "la clave no es una clave de grupo: borre el remitente o use una clave de grupo"},
+		{otp.ErrInvalidOffset, "el desplazamiento está fuera de la clave: use un desplazamiento menor o una clave nueva"},
+		{otp.ErrKeyTooSmall, "el tamaño de la clave debe ser al menos un byte"},
+		{otp.ErrKeyTooLarge, "el tamaño de la clave es demasiado grande: use como máximo " + strconv.Itoa(otp.MaxKeyLength) + " bytes"},
+		{otp.ErrInvalidBlock, "una clave o texto cifrado está dañado: cópielo de nuevo, incluidas las líneas BEGIN y END"},
+		{otp.ErrMultipleBlocks, "se encontró más de una clave o texto cifrado: use solo uno"},
+		{otp.ErrLeadingData, "hay texto adicional antes de la línea BEGIN: elimine todo lo que la precede"},
+		{otp.ErrTrailingData, "hay texto adicional después de la línea END: elimine todo lo que le sigue"},
+		{otp.ErrNoPEM, "no se encontró una clave ni un texto cifrado: pegue el archivo completo, incluidas las líneas BEGIN y END"},
+	},
+}
+
+// actionDescriptions translate the actions that fail with errors, by language, keyed by the English action.
+// Actions can have a {name} placeholder for the name of what the action is done to, which is not translated.
+var actionDescriptions = map[string]map[string]string{
+	"es": {
+		"could not read key":             "no se pudo leer la clave",
+		"cannot use key":                 "no se puede usar la clave",
+		"could not create key file":      "no se pudo crear el archivo de clave",
+		"could not protect key file":     "no se pudo proteger el archivo de clave",
+		"could not encrypt message":      "no se pudo cifrar el mensaje",
+		"could not decrypt cipher":       "no se pudo descifrar el texto cifrado",
+		"invalid file":                   "archivo no válido",
+		"invalid text":                   "texto no válido",
+		"invalid protected file":         "archivo protegido no válido",
+		"invalid protected text":         "texto protegido no válido",
+		"could not unprotect file":       "no se pudo desproteger el archivo",
+		"could not unprotect text":       "no se pudo desproteger el texto",
+		"could not unlock vault":         "no se pudo desbloquear la bóveda",
+		"could not add key to vault":     "no se pudo agregar la clave a la bóveda",
+		"could not use vault key":        "no se pudo usar la clave de la bóveda",
+		"could not export {name}":        "no se pudo exportar {name}",
+		"could not export vault":         "no se pudo exportar la bóveda",
+		"could not join chat":            "no se pudo unir al chat",
+		"could not encrypt chat message": "no se pudo cifrar el mensaje del chat",
+		"could not decrypt chat message": "no se pudo descifrar el mensaje del chat",
+	},
+}
+
+// describeError explains the error in the language of the user's browser.
+// Errors from the otp package are described with how to fix them, other errors are described by their text.
+func describeError(err error) string {
+	for _, d := range errorDescriptions[language()] {
+		if !errors.Is(err, d.err) {
+			continue
+		}
+		description := d.description
+		var lengthErr *otp.LengthError
+		if errors.As(err, &lengthErr) {
+			r := strings.NewReplacer(
+				"{length}", strconv.Itoa(lengthErr.Length),
+				"{capacity}", strconv.Itoa(lengthErr.Capacity),
+				"{over}", strconv.Itoa(lengthErr.Length-lengthErr.Capacity),
+			)
+			description = r.Replace(description)
+		}
+		return description
+	}
+	return err.Error()
+}
+
+// describeFailure explains that the action failed because of the error in the language of the user's browser.
+func describeFailure(action string, err error) string {
+	return describeNamedFailure(action, "", err)
+}
+
+// describeNamedFailure explains that the action on the name failed because of the error in the language of the user's browser.
+// The {name} placeholder of the action is replaced with the name.
+func describeNamedFailure(action, name string, err error) string {
+	if translated, ok := actionDescriptions[language()][action]; ok {
+		action = translated
+	}
+	action = strings.ReplaceAll(action, "{name}", name)
+	return action + ": " + describeError(err)
+}
+
+// language is the primary language of the user's browser that errors can be described in.
+func language() string {
+	global := js.Global()
+	navigator := global.Get("navigator")
+	if navigator.Truthy() {
+		lang := navigator.Get("language")
+		if lang.Truthy() {
+			primary, _, _ := strings.Cut(strings.ToLower(lang.String()), "-")
+			if _, ok := errorDescriptions[primary]; ok {
+				return primary
+			}
+		}
+	}
+	return defaultLanguage
+}
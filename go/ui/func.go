@@ -14,13 +14,21 @@ func InitDomFuncs(ctx context.Context, wg *sync.WaitGroup) {
 		"clear": NewJsFunc(clearLog),
 	}
 	otpFuncs := map[string]js.Func{
-		"encrypt":     NewJsEventFunc(encryptMessage),
-		"decrypt":     NewJsEventFunc(decryptCipher),
-		"generateKey": NewJsEventFunc(generateKey),
+		"encrypt":        NewJsAsyncEventFunc(encryptMessage),
+		"decrypt":        NewJsAsyncEventFunc(decryptCipher),
+		"generateKey":    NewJsEventFunc(generateKey),
+		"downloadCipher": NewJsEventFunc(downloadCipher),
+		"fetchMailbox":   NewJsAsyncEventFunc(fetchMailbox),
+		"checkReceipt":   NewJsAsyncEventFunc(checkReceipt),
 	}
 	RegisterFuncs(ctx, wg, "log", logFuncs)
 	RegisterFuncs(ctx, wg, "otp", otpFuncs)
 	initOtp(ctx, wg)
+	initKey(ctx, wg)
+	initAttachments(ctx, wg)
+	initVault(ctx, wg)
+	initHistory(ctx, wg)
+	initChat(ctx, wg)
 }
 
 // NewJsFunc creates a new javascript function from the provided function.
@@ -44,6 +52,20 @@ func NewJsEventFunc(fn func(event js.Value)) js.Func {
 	})
 }
 
+// NewJsAsyncEventFunc creates a new javascript function like NewJsEventFunc, but the function is run on a separate goroutine.
+// This allows the function to wait for other javascript callbacks, such as those of IndexedDB requests.
+func NewJsAsyncEventFunc(fn func(event js.Value)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		event := args[0]
+		event.Call("preventDefault")
+		go func() {
+			defer AlertOnPanic()
+			fn(event)
+		}()
+		return nil
+	})
+}
+
 // RegisterFuncs sets the function as fields on the parent.
 // The parent object is created if it does not exist.
 func RegisterFuncs(ctx context.Context, wg *sync.WaitGroup, parentName string, jsFuncs map[string]js.Func) {
@@ -0,0 +1,226 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+// unknownPartner is the partner of messages encrypted with keys that are not in the vault.
+const unknownPartner = "unknown partner"
+
+// historyEntry is a sent or received message in the conversation history.
+// Entries are sealed with the vault lock when they are stored.
+type historyEntry struct {
+	ID          string `json:"-"`
+	Partner     string `json:"partner"`
+	Fingerprint string `json:"fingerprint"`
+	Sent        bool   `json:"sent"`
+	Sender      string `json:"sender,omitempty"`
+	Text        string `json:"text"`
+	Time        int64  `json:"time"`
+	// Offset is the index of the first byte of the key that encrypted the message.
+	Offset int `json:"offset"`
+}
+
+// historyEntries are the messages in the unlocked vault, by id.
+// They are guarded by the vaultMu.
+var historyEntries map[string]historyEntry
+
+// initHistory registers the conversation history javascript functions.
+// The parent object is named "conversation" because the browser has a global history object.
+func initHistory(ctx context.Context, wg *sync.WaitGroup) {
+	historyFuncs := map[string]js.Func{
+		"show":  NewJsFunc(renderHistory),
+		"clear": NewJsAsyncEventFunc(clearHistory),
+	}
+	RegisterFuncs(ctx, wg, "conversation", historyFuncs)
+}
+
+// loadHistory opens all of the sealed messages in the vault.
+func loadHistory(l otp.Lock) (map[string]historyEntry, error) {
+	entries := make(map[string]historyEntry)
+	err := getAllSealed(l, messagesStore, func(id string, data []byte) error {
+		var e historyEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return err
+		}
+		e.ID = id
+		entries[id] = e
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// recordMessage adds the message to the history of the partner who shares the key, if the vault is unlocked and history is kept.
+// The message is stored on a separate goroutine because it waits for javascript callbacks.
+func recordMessage(sent bool, text string, key *otp.Key, cipher string) {
+	vaultMu.Lock()
+	unlocked := vaultLock != nil
+	vaultMu.Unlock()
+	if !unlocked || !Checked("#history-keep") {
+		return
+	}
+	fingerprint := key.Fingerprint()
+	opts, err := otp.ParseOptions(cipher)
+	if err != nil {
+		logError("could not add message to history: " + err.Error())
+		return
+	}
+	id, err := newID()
+	if err != nil {
+		logError("could not add message to history: " + err.Error())
+		return
+	}
+	e := historyEntry{
+		ID:          id,
+		Partner:     partnerFor(fingerprint),
+		Fingerprint: fingerprint,
+		Sent:        sent,
+		Sender:      opts.Sender,
+		Text:        text,
+		Time:        time.Now().Unix(),
+		Offset:      opts.Offset,
+	}
+	go func() {
+		defer AlertOnPanic()
+		if err := putSealed(messagesStore, e.ID, e); err != nil {
+			logError("could not add message to history: " + err.Error())
+			return
+		}
+		vaultMu.Lock()
+		if historyEntries != nil {
+			historyEntries[e.ID] = e
+		}
+		vaultMu.Unlock()
+		renderHistory()
+	}()
+}
+
+// partnerFor finds the partner of the vault key with the fingerprint.
+// The label of the key is used if the key has no partner.
+func partnerFor(fingerprint string) string {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	for _, e := range vaultEntries {
+		if e.Fingerprint == fingerprint {
+			if len(e.Partner) != 0 {
+				return e.Partner
+			}
+			return e.Label
+		}
+	}
+	return unknownPartner
+}
+
+// renderHistory shows the partners with messages and the messages of the selected partner, in the order they were sent or received.
+func renderHistory() {
+	vaultMu.Lock()
+	entries := make([]historyEntry, 0, len(historyEntries))
+	for _, e := range historyEntries {
+		entries = append(entries, e)
+	}
+	vaultMu.Unlock()
+	slices.SortFunc(entries, func(a, b historyEntry) int {
+		return cmp.Or(cmp.Compare(a.Time, b.Time), cmp.Compare(a.Offset, b.Offset))
+	})
+	partner := renderHistoryPartners(entries)
+	thread := QuerySelector(".history-thread")
+	thread.Set("innerHTML", "")
+	for _, e := range entries {
+		if e.Partner != partner {
+			continue
+		}
+		clone := CloneElement(".history-message")
+		item := clone.Get("children").Index(0)
+		direction := "received"
+		if e.Sent {
+			direction = "sent"
+			item.Get("classList").Call("add", "sent")
+		}
+		if len(e.Sender) != 0 {
+			direction += " (" + e.Sender + ")"
+		}
+		for query, text := range map[string]string{
+			".time":      time.Unix(e.Time, 0).Format("2006-01-02 15:04:05"),
+			".direction": direction,
+			".offset":    "key offset " + strconv.Itoa(e.Offset),
+			".text":      e.Text,
+		} {
+			element := item.Call("querySelector", query)
+			element.Set("textContent", text)
+		}
+		thread.Call("appendChild", item)
+	}
+}
+
+// renderHistoryPartners shows the partners of the entries as options, keeping the selected partner if possible.
+// The selected partner is returned.
+func renderHistoryPartners(entries []historyEntry) string {
+	selected := Value("#history-partner")
+	var partners []string
+	for _, e := range entries {
+		if !slices.Contains(partners, e.Partner) {
+			partners = append(partners, e.Partner)
+		}
+	}
+	slices.Sort(partners)
+	if !slices.Contains(partners, selected) && len(partners) != 0 {
+		selected = partners[0]
+	}
+	global := js.Global()
+	document := global.Get("document")
+	partnerSelect := QuerySelector("#history-partner")
+	partnerSelect.Set("innerHTML", "")
+	for _, p := range partners {
+		option := document.Call("createElement", "option")
+		option.Set("value", p)
+		option.Set("textContent", p)
+		partnerSelect.Call("appendChild", option)
+	}
+	partnerSelect.Set("value", selected)
+	return selected
+}
+
+// clearHistory deletes the messages of the selected partner after the user confirms it.
+func clearHistory(event js.Value) {
+	partner := Value("#history-partner")
+	if len(partner) == 0 {
+		return
+	}
+	global := js.Global()
+	if !global.Call("confirm", "Delete all messages with "+partner+"?").Bool() {
+		return
+	}
+	vaultMu.Lock()
+	var ids []string
+	for id, e := range historyEntries {
+		if e.Partner == partner {
+			ids = append(ids, id)
+		}
+	}
+	vaultMu.Unlock()
+	for _, id := range ids {
+		if err := idbDelete(messagesStore, id); err != nil {
+			logError("could not delete message: " + err.Error())
+			return
+		}
+		vaultMu.Lock()
+		delete(historyEntries, id)
+		vaultMu.Unlock()
+	}
+	renderHistory()
+	logInfo("deleted history with " + partner)
+}
@@ -0,0 +1,150 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+const (
+	// dbName is the name of the IndexedDB database of the site.
+	dbName = "sarah-otp"
+	// dbVersion is the version of the database, which must be incremented when object stores are added.
+	dbVersion = 2
+	// metaStore is the object store for settings, such as the salt for the vault passphrase.
+	metaStore = "meta"
+	// keysStore is the object store for sealed vault keys.
+	keysStore = "keys"
+	// messagesStore is the object store for sealed messages in the conversation history.
+	messagesStore = "messages"
+)
+
+// db is the open IndexedDB database, which is undefined until openDB succeeds.
+var db js.Value
+
+// openDB opens the database, creating the object stores if they do not exist.
+// The database is opened once and reused.
+// This must be called on a separate goroutine because it waits for javascript callbacks.
+func openDB() (js.Value, error) {
+	if db.Truthy() {
+		return db, nil
+	}
+	global := js.Global()
+	indexedDB := global.Get("indexedDB")
+	if !indexedDB.Truthy() {
+		return js.Undefined(), errors.New("IndexedDB not available")
+	}
+	request := indexedDB.Call("open", dbName, dbVersion)
+	upgradeJsFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		defer AlertOnPanic()
+		upgradeDB := request.Get("result")
+		storeNames := upgradeDB.Get("objectStoreNames")
+		for _, name := range []string{metaStore, keysStore, messagesStore} {
+			if !storeNames.Call("contains", name).Bool() {
+				options := map[string]interface{}{
+					"keyPath": "id",
+				}
+				upgradeDB.Call("createObjectStore", name, options)
+			}
+		}
+		return nil
+	})
+	defer upgradeJsFunc.Release()
+	request.Set("onupgradeneeded", upgradeJsFunc)
+	result, err := awaitRequest(request)
+	if err != nil {
+		return js.Undefined(), errors.New("opening database: " + err.Error())
+	}
+	db = result
+	return db, nil
+}
+
+// awaitRequest waits for the IndexedDB request to succeed or fail, returning its result.
+// This must be called on a separate goroutine because it waits for javascript callbacks.
+func awaitRequest(request js.Value) (js.Value, error) {
+	type result struct {
+		value js.Value
+		err   error
+	}
+	done := make(chan result, 1)
+	onSuccessJsFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done <- result{value: request.Get("result")}
+		return nil
+	})
+	defer onSuccessJsFunc.Release()
+	onErrorJsFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		err := request.Get("error")
+		done <- result{err: errors.New(err.Call("toString").String())}
+		return nil
+	})
+	defer onErrorJsFunc.Release()
+	request.Set("onsuccess", onSuccessJsFunc)
+	request.Set("onerror", onErrorJsFunc)
+	r := <-done // BLOCKING
+	return r.value, r.err
+}
+
+// storeRequest opens the object store in a new transaction and awaits the request created by calling the method with the arguments.
+func storeRequest(storeName, mode, method string, args ...interface{}) (js.Value, error) {
+	db, err := openDB()
+	if err != nil {
+		return js.Undefined(), err
+	}
+	transaction := db.Call("transaction", storeName, mode)
+	store := transaction.Call("objectStore", storeName)
+	request := store.Call(method, args...)
+	result, err := awaitRequest(request)
+	if err != nil {
+		return js.Undefined(), errors.New(method + " " + storeName + ": " + err.Error())
+	}
+	return result, nil
+}
+
+// idbGet gets the record with the id from the object store.
+// The record is undefined if it does not exist.
+func idbGet(storeName, id string) (js.Value, error) {
+	return storeRequest(storeName, "readonly", "get", id)
+}
+
+// idbGetAll gets all records from the object store.
+func idbGetAll(storeName string) ([]js.Value, error) {
+	result, err := storeRequest(storeName, "readonly", "getAll")
+	if err != nil {
+		return nil, err
+	}
+	records := make([]js.Value, result.Length())
+	for i := range records {
+		records[i] = result.Index(i)
+	}
+	return records, nil
+}
+
+// idbPut adds or replaces the record in the object store.
+// The record must have an id.
+func idbPut(storeName string, record map[string]interface{}) error {
+	_, err := storeRequest(storeName, "readwrite", "put", record)
+	return err
+}
+
+// idbDelete deletes the record with the id from the object store.
+func idbDelete(storeName, id string) error {
+	_, err := storeRequest(storeName, "readwrite", "delete", id)
+	return err
+}
+
+// jsBytes copies the bytes to a new javascript Uint8Array.
+func jsBytes(b []byte) js.Value {
+	global := js.Global()
+	uint8Array := global.Get("Uint8Array")
+	a := uint8Array.New(len(b))
+	js.CopyBytesToJS(a, b)
+	return a
+}
+
+// goBytes copies the javascript Uint8Array to a new byte slice.
+func goBytes(a js.Value) []byte {
+	b := make([]byte, a.Length())
+	js.CopyBytesToGo(b, a)
+	return b
+}
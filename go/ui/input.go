@@ -0,0 +1,230 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"bytes"
+	"strconv"
+	"syscall/js"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+// addPemInput registers functions to the map to read PEM text for the read function.
+// The text is read from a file input, pasted into the text area after the file input, or read from a file that is dropped on the input.
+// Text around the PEM data, such as the rest of an email, is ignored.
+// Keys that are protected with a passphrase are unprotected after the user enters the passphrase in a password input that is added to the input.
+// The file input and submit button are disabled until a file is read.
+// The status after the text area shows whether or not the text can be decoded.
+// Several PEM blocks can only be read when bundle is true.
+// The read function is called with the PEM text after it changes, or nil if the text is not valid.
+// The text is cleared after the read function returns, so it must be copied to be kept.
+// Secret text, such as keys, is removed from the inputs after it is read.
+func addPemInput(jsFuncs map[string]js.Func, fileInputQuery, submitButtonQuery string, bundle, secret bool, read func(text []byte)) {
+	textQuery := fileInputQuery + "-text"
+	statusQuery := fileInputQuery + "-status"
+	fileInput := QuerySelector(fileInputQuery)
+	dropZone := fileInput.Get("parentElement")
+	setStatusError := func(message string) {
+		status := QuerySelector(statusQuery)
+		status.Set("textContent", message)
+		status.Get("classList").Call("add", "error")
+	}
+	readText := func(text []byte, source string) {
+		if !validatePemInput(statusQuery, text, source, bundle) {
+			read(nil)
+			return
+		}
+		read(text)
+		if secret && len(text) != 0 {
+			fileInput.Set("value", "")
+			SetValue(textQuery, "")
+		}
+	}
+	passphraseClone := CloneElement(".pem-passphrase")
+	passphraseRow := passphraseClone.Get("children").Index(0)
+	dropZone.Call("appendChild", passphraseRow)
+	passphraseInput := passphraseRow.Call("querySelector", "input")
+	// protectedText is copied from protected text that is read until the passphrase is entered.
+	// Changes to the text while the passphrase is being entered replace it instead of asking for the passphrase again.
+	var protectedText []byte
+	var protectedSource string
+	forgetProtectedText := func() {
+		clear(protectedText)
+		protectedText = nil
+		passphraseRow.Set("hidden", true)
+	}
+	setText := func(text []byte, source string) {
+		defer clear(text)
+		if !otp.IsProtected(text) {
+			forgetProtectedText()
+			readText(text, source)
+			return
+		}
+		read(nil)
+		asking := len(protectedText) != 0
+		clear(protectedText)
+		protectedText, protectedSource = bytes.Clone(text), source
+		setStatusError("enter the passphrase of the protected " + source)
+		if !asking {
+			passphraseRow.Set("hidden", false)
+			passphraseInput.Call("focus")
+		}
+	}
+	unprotectJsFunc := NewJsAsyncEventFunc(func(event js.Value) {
+		passphrase := passphraseInput.Get("value").String()
+		passphraseInput.Set("value", "")
+		text, source := protectedText, protectedSource
+		if len(text) == 0 {
+			return
+		}
+		if len(passphrase) == 0 {
+			setStatusError(describeFailure("could not unprotect "+source, otp.ErrProtectedKey))
+			return
+		}
+		logInfo("unprotecting key...")
+		unprotected, err := otp.Unprotect(text, passphrase)
+		if err != nil {
+			setStatusError(describeFailure("could not unprotect "+source, err))
+			return
+		}
+		defer clear(unprotected)
+		forgetProtectedText()
+		readText(unprotected, "protected "+source)
+	})
+	passphraseKeyDownJsFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		event := args[0]
+		if event.Get("key").String() == "Enter" {
+			event.Call("preventDefault") // do not submit the form of the input
+			passphraseRow.Call("querySelector", "button").Call("click")
+		}
+		return nil
+	})
+	passphraseRow.Call("querySelector", "button").Call("addEventListener", "click", unprotectJsFunc)
+	passphraseInput.Call("addEventListener", "keydown", passphraseKeyDownJsFunc)
+	jsFuncs[fileInputQuery+"_unprotect"] = unprotectJsFunc
+	jsFuncs[fileInputQuery+"_passphraseKeyDown"] = passphraseKeyDownJsFunc
+	global := js.Global()
+	fileReader := global.Get("FileReader")
+	uint8Array := global.Get("Uint8Array")
+	readFile := func(file js.Value) {
+		if !file.Truthy() {
+			return
+		}
+		SetButtonDisabled(fileInputQuery, true)
+		SetButtonDisabled(submitButtonQuery, true)
+		reader := fileReader.New() // a new reader for each file so results are not kept
+		var readEventsJsFunc js.Func
+		readEventsJsFunc = NewJsEventFunc(func(event js.Value) {
+			defer readEventsJsFunc.Release()
+			SetButtonDisabled(fileInputQuery, false)
+			SetButtonDisabled(submitButtonQuery, false)
+			eventType := event.Get("type").String()
+			switch eventType {
+			case "load":
+				result := uint8Array.New(reader.Get("result"))
+				text := goBytes(result)
+				result.Call("fill", 0)
+				SetValue(textQuery, "")
+				setText(text, "file")
+			case "abort":
+				logInfo("reading file aborted for: " + fileInputQuery)
+			case "error":
+				logError("error reading file: " + fileInputQuery)
+			default:
+				logError("unknown file read event: " + eventType)
+			}
+		})
+		reader.Call("addEventListener", "load", readEventsJsFunc)
+		reader.Call("addEventListener", "abort", readEventsJsFunc)
+		reader.Call("addEventListener", "error", readEventsJsFunc)
+		reader.Call("readAsArrayBuffer", file)
+	}
+	textEncoder := global.Get("TextEncoder").New()
+	inputChangeJsFunc := NewJsEventFunc(func(event js.Value) {
+		files := fileInput.Get("files")
+		readFile(files.Index(0))
+	})
+	textInputJsFunc := NewJsFunc(func() {
+		fileInput.Set("value", "")
+		encoded := textEncoder.Call("encode", Value(textQuery))
+		text := goBytes(encoded)
+		encoded.Call("fill", 0)
+		setText(text, "text")
+	})
+	dragOverJsFunc := NewJsEventFunc(func(event js.Value) {
+		dropZone.Get("classList").Call("add", "dragging")
+	})
+	dragLeaveJsFunc := NewJsEventFunc(func(event js.Value) {
+		dropZone.Get("classList").Call("remove", "dragging")
+	})
+	dropJsFunc := NewJsEventFunc(func(event js.Value) {
+		dropZone.Get("classList").Call("remove", "dragging")
+		files := event.Get("dataTransfer").Get("files")
+		if files.Length() != 0 {
+			fileInput.Set("value", "")
+			readFile(files.Index(0))
+		}
+	})
+	fileInput.Call("addEventListener", "change", inputChangeJsFunc)
+	QuerySelector(textQuery).Call("addEventListener", "input", textInputJsFunc)
+	dropZone.Call("addEventListener", "dragover", dragOverJsFunc)
+	dropZone.Call("addEventListener", "dragleave", dragLeaveJsFunc)
+	dropZone.Call("addEventListener", "drop", dropJsFunc)
+	jsFuncs[fileInputQuery+"_inputChange"] = inputChangeJsFunc
+	jsFuncs[fileInputQuery+"_textInput"] = textInputJsFunc
+	jsFuncs[fileInputQuery+"_dragOver"] = dragOverJsFunc
+	jsFuncs[fileInputQuery+"_dragLeave"] = dragLeaveJsFunc
+	jsFuncs[fileInputQuery+"_drop"] = dropJsFunc
+}
+
+// validatePemInput shows whether or not the PEM text from the source can be decoded in the status element.
+// Every block of a bundle must be decoded, and the number of blocks is shown if there are several.
+// Empty text is valid.
+func validatePemInput(statusQuery string, text []byte, source string, bundle bool) bool {
+	status := QuerySelector(statusQuery)
+	n := 1
+	var err error
+	switch {
+	case len(text) == 0:
+	case bundle:
+		n, err = otp.ValidateBundle(text)
+	default:
+		err = otp.Validate(text)
+	}
+	switch {
+	case len(text) == 0:
+		status.Set("textContent", "")
+	case err != nil:
+		status.Set("textContent", describeFailure("invalid "+source, err))
+	case n > 1:
+		status.Set("textContent", "read "+strconv.Itoa(n)+" blocks from "+source)
+	default:
+		status.Set("textContent", "read from "+source)
+	}
+	status.Get("classList").Call("toggle", "error", err != nil)
+	return err == nil
+}
+
+// setKey replaces the key with the key in the text, destroying the previous key.
+// The key is nil if the text is empty.
+func setKey(key **otp.Key, text []byte) error {
+	destroyKey(key)
+	if len(text) == 0 {
+		return nil
+	}
+	k, err := otp.ParseKey(text)
+	if err != nil {
+		return err
+	}
+	*key = k
+	return nil
+}
+
+// destroyKey destroys the key, if any, and forgets it.
+func destroyKey(key **otp.Key) {
+	if *key != nil {
+		(*key).Destroy()
+		*key = nil
+	}
+}
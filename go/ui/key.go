@@ -0,0 +1,186 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall/js"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+// maxKeyEntropyLength is the most bytes of mouse movements that are collected to mix into keys.
+const maxKeyEntropyLength = 4096
+
+// keyEntropy is the collected mouse movements to mix into the next key.
+var keyEntropy []byte
+
+// initKey registers functions to change the key size from presets, toggle the randomness input, and collect mouse movements.
+func initKey(ctx context.Context, wg *sync.WaitGroup) {
+	presetChangeJsFunc := NewJsFunc(func() {
+		if preset := Value("#key-size-preset"); len(preset) != 0 {
+			SetValue("#key-size", preset)
+		}
+	})
+	sizeInputJsFunc := NewJsFunc(func() {
+		SetValue("#key-size-preset", Value("#key-size"))
+		if len(Value("#key-size-preset")) == 0 { // not a preset
+			SetValue("#key-size-preset", "")
+		}
+	})
+	entropyChangeJsFunc := NewJsFunc(func() {
+		entropyInput := QuerySelector(".key-entropy-input")
+		entropyInput.Set("hidden", Value("#key-entropy") == "browser")
+	})
+	mouseMoveJsFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		event := args[0]
+		if len(keyEntropy) < maxKeyEntropyLength {
+			keyEntropy = binary.BigEndian.AppendUint16(keyEntropy, uint16(event.Get("clientX").Int()))
+			keyEntropy = binary.BigEndian.AppendUint16(keyEntropy, uint16(event.Get("clientY").Int()))
+			keyEntropy = binary.BigEndian.AppendUint64(keyEntropy, uint64(event.Get("timeStamp").Float()*1000))
+		}
+		return nil
+	})
+	QuerySelector("#key-size-preset").Call("addEventListener", "change", presetChangeJsFunc)
+	QuerySelector("#key-size").Call("addEventListener", "input", sizeInputJsFunc)
+	QuerySelector("#key-entropy").Call("addEventListener", "change", entropyChangeJsFunc)
+	QuerySelector("#key-entropy-text").Call("addEventListener", "mousemove", mouseMoveJsFunc)
+	jsFuncs := map[string]js.Func{
+		"#key-size-preset_change": presetChangeJsFunc,
+		"#key-size_input":         sizeInputJsFunc,
+		"#key-entropy_change":     entropyChangeJsFunc,
+		"#key-entropy-text_move":  mouseMoveJsFunc,
+	}
+	wg.Add(1)
+	go ReleaseJsFuncsOnDone(ctx, wg, jsFuncs)
+}
+
+// generateKey is executed when the user creates a new key.
+// Two copies are downloaded if requested: one to keep and one for the partner.
+func generateKey(event js.Value) {
+	keySizeText := Value("#key-size")
+	keySize, err := strconv.Atoi(keySizeText)
+	if err != nil {
+		logError("could not convert key size to number: " + err.Error())
+		return
+	}
+	opts := otp.KeyOptions{
+		Label:   strings.TrimSpace(Value("#key-label")),
+		Partner: strings.TrimSpace(Value("#key-partner")),
+	}
+	typed := Value("#key-entropy-text")
+	switch Value("#key-entropy") {
+	case "mixed":
+		if len(typed) == 0 && len(keyEntropy) == 0 {
+			logError("type random characters or move the mouse over the randomness box first")
+			return
+		}
+		opts.Entropy = append([]byte(typed), keyEntropy...)
+	case "insecure":
+		if len(typed) == 0 {
+			logError("type a seed in the randomness box first")
+			return
+		}
+		opts.Rand = otp.NewInsecureReader([]byte(typed))
+		logError("WARNING: creating an INSECURE key that anyone with the seed can reproduce")
+	}
+	var key []byte
+	switch members := strings.TrimSpace(Value("#key-members")); {
+	case len(members) != 0:
+		key, err = opts.GenerateGroupKey(splitMembers(members), keySize)
+	default:
+		key, err = opts.GenerateKey(keySize)
+	}
+	if err != nil {
+		logError(describeFailure("could not create key file", err))
+		return
+	}
+	keyEntropy = nil
+	SetValue("#key-entropy-text", "")
+	fingerprint, err := otp.Fingerprint(key)
+	if err != nil {
+		clear(key)
+		logError("could not create key fingerprint: " + err.Error())
+		return
+	}
+	SetText("#key-fingerprint", fingerprint)
+	passphrase := Value("#key-passphrase")
+	SetValue("#key-passphrase", "")
+	var kept *otp.Key
+	if Checked("#key-vault") {
+		kept, err = otp.ParseKey(key)
+		if err != nil {
+			clear(key)
+			logError(describeFailure("could not add key to vault", err))
+			return
+		}
+	}
+	go func() {
+		defer AlertOnPanic()
+		defer clear(key)
+		saveKeyCopies(key, opts, passphrase)
+	}()
+	if kept != nil {
+		go func() {
+			defer AlertOnPanic()
+			if err := addToVault(opts.Label, opts.Partner, "", kept, 0); err != nil {
+				logError("could not add key to vault: " + err.Error())
+			}
+		}()
+	}
+}
+
+// saveKeyCopies downloads the key, or two copies of it if requested.
+// The copies are protected with the passphrase, if it is not empty.
+// This should be called on a separate goroutine because protecting keys is slow.
+// The caller should clear the key after it is saved.
+func saveKeyCopies(key []byte, opts otp.KeyOptions, passphrase string) {
+	if len(passphrase) != 0 {
+		logInfo("protecting key with passphrase...")
+		protected, err := otp.Protect(key, passphrase)
+		if err != nil {
+			logError(describeFailure("could not protect key file", err))
+			return
+		}
+		defer clear(protected)
+		key = protected
+	}
+	name := "key"
+	if len(opts.Label) != 0 {
+		name += "_" + fileNamePart(opts.Label)
+	}
+	if !Checked("#key-copies") {
+		savePem(name, key)
+		return
+	}
+	partner := "partner"
+	if len(opts.Partner) != 0 {
+		partner = fileNamePart(opts.Partner)
+	}
+	savePem(name+"_mine", key)
+	savePem(name+"_for_"+partner, key)
+}
+
+// fileNamePart replaces characters that should not be in file names with dashes.
+func fileNamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-':
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// splitMembers splits the comma-separated member names, removing surrounding whitespace.
+func splitMembers(members string) []string {
+	m := strings.Split(members, ",")
+	for i := range m {
+		m[i] = strings.TrimSpace(m[i])
+	}
+	return m
+}
@@ -0,0 +1,138 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall/js"
+)
+
+// lastReceipt is the token of the receipt of the most recently posted mailbox.
+var lastReceipt string
+
+// postMailbox sends the cipher to a new mailbox on the server and shows the link to read it.
+// The server only stores the cipher text, so the key must be shared separately.
+// The mailbox is deleted when it is read or when it expires.
+func postMailbox(cipher []byte) {
+	global := js.Global()
+	init := map[string]interface{}{
+		"method": "POST",
+		"body":   string(cipher),
+	}
+	response, err := fetch("/mailbox", init)
+	if err != nil {
+		logError("could not post to mailbox, showing text instead: " + err.Error())
+		showCipher(cipher)
+		return
+	}
+	mailbox, err := Await(response.Call("json"))
+	if err != nil {
+		logError("could not read mailbox: " + err.Error())
+		return
+	}
+	lastReceipt = mailbox.Get("receipt").String()
+	link := global.Get("location").Get("origin").String() + mailbox.Get("link").String()
+	expires := global.Get("Date").New(mailbox.Get("expires"))
+	SetValue("#encrypt-mailbox-link", link)
+	logInfo("posted to mailbox, which can be read once before " + expires.Call("toLocaleString").String())
+	showDownload()
+}
+
+// checkReceipt is executed when the user checks whether or not the last posted mailbox has been read.
+func checkReceipt(event js.Value) {
+	if len(lastReceipt) == 0 {
+		logError("no mailbox to check")
+		return
+	}
+	response, err := fetch("/receipt/"+url.PathEscape(lastReceipt), nil)
+	if err != nil {
+		logError("could not check receipt: " + err.Error())
+		return
+	}
+	receipt, err := Await(response.Call("json"))
+	if err != nil {
+		logError("could not read receipt: " + err.Error())
+		return
+	}
+	fetched := receipt.Get("fetched")
+	if !fetched.Truthy() {
+		logInfo("the mailbox has not been read yet")
+		return
+	}
+	global := js.Global()
+	fetchedTime := global.Get("Date").New(fetched)
+	logInfo("the mailbox was read at " + fetchedTime.Call("toLocaleString").String())
+}
+
+// fetchMailbox is executed when the user fetches cipher text from a mailbox to decrypt.
+// The mailbox can be identified by its id or its read link.
+// The mailbox is deleted by the server when it is read, so it can only be fetched once.
+func fetchMailbox(event js.Value) {
+	id := strings.TrimSpace(Value("#decrypt-mailbox-id"))
+	id = strings.TrimRight(id, "/")
+	id = id[strings.LastIndex(id, "/")+1:]
+	if len(id) == 0 {
+		logError("could not fetch mailbox: enter the mailbox id first")
+		return
+	}
+	response, err := fetch("/mailbox/"+url.PathEscape(id), nil)
+	if err != nil {
+		logError("could not fetch mailbox: " + err.Error())
+		return
+	}
+	text, err := Await(response.Call("text"))
+	if err != nil {
+		logError("could not read mailbox: " + err.Error())
+		return
+	}
+	SetValue("#decrypt-mailbox-id", "")
+	SetValue("#decrypt-cipher-text", text.String())
+	readCipherText()
+	global := js.Global()
+	if strings.HasPrefix(global.Get("location").Get("pathname").String(), "/read/") {
+		// The address is replaced so reloading the page does not show the link to the deleted mailbox again.
+		global.Get("history").Call("replaceState", nil, "", "/")
+	}
+	logInfo("fetched mailbox " + id + ", which has been deleted from the server")
+}
+
+// readLinkMailbox tells the user to fetch the mailbox of the read link that opened the page.
+// The server does not delete the mailbox until it is fetched, so the message is not lost when a link preview gets the link.
+func readLinkMailbox() {
+	if len(Value("#decrypt-mailbox-id")) == 0 {
+		return
+	}
+	logInfo("fetch the mailbox to read the encrypted message, which deletes it from the server")
+}
+
+// readCipherText reads the cipher text in the text area of the decrypt tab as if it were typed.
+func readCipherText() {
+	global := js.Global()
+	inputEvent := global.Get("Event").New("input")
+	QuerySelector("#decrypt-cipher-text").Call("dispatchEvent", inputEvent)
+}
+
+// fetch requests the resource from the server, returning the response if its status is ok.
+// This must be called on a separate goroutine because it waits for the response.
+func fetch(resource string, init map[string]interface{}) (js.Value, error) {
+	global := js.Global()
+	var promise js.Value
+	switch {
+	case init == nil:
+		promise = global.Call("fetch", resource)
+	default:
+		promise = global.Call("fetch", resource, init)
+	}
+	response, err := Await(promise)
+	switch {
+	case err != nil:
+		return js.Undefined(), err
+	case !response.Get("ok").Bool():
+		status := response.Get("status").Int()
+		return js.Undefined(), errors.New("server responded with status " + strconv.Itoa(status) + " " + response.Get("statusText").String())
+	}
+	return response, nil
+}
@@ -4,6 +4,7 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,117 +15,198 @@ import (
 )
 
 var (
-	encryptKeyReader    js.Value
-	decryptKeyReader    js.Value
-	decryptCipherReader js.Value
-	encryptKey          string
-	decryptKey          string
-	decryptCipherText   string
+	// encryptKey and decryptKey are destroyed when they are replaced.
+	encryptKey        *otp.Key
+	decryptKey        *otp.Key
+	decryptCipherText string
 )
 
-// addFileReader registers functions to the map to disable the file input and submit button until the file is read to the destination.
-func addFileReader(jsFuncs map[string]js.Func, reader *js.Value, fileDestination *string, fileInputQuery, submitButtonQuery string) {
-	global := js.Global()
-	fileReader := global.Get("FileReader")
-	*reader = fileReader.New()
-	readEventsJsFunc := NewJsEventFunc(func(event js.Value) {
-		eventType := event.Get("type").String()
-		switch eventType {
-		case "load":
-			SetButtonDisabled(fileInputQuery, false)
-			SetButtonDisabled(submitButtonQuery, false)
-			result := reader.Get("result")
-			*fileDestination = result.String()
-		case "abort":
-			logInfo("reading file aborted for: " + fileInputQuery)
-		case "error":
-			logError("error reading file: " + fileInputQuery)
-		default:
-			logError("unknown file read event: " + eventType)
-		}
-		SetButtonDisabled(fileInputQuery, false)
-		SetButtonDisabled(submitButtonQuery, false)
-	})
-	fileInput := QuerySelector(fileInputQuery)
-	inputChangeJsFunc := NewJsEventFunc(func(event js.Value) {
-		files := fileInput.Get("files")
-		file := files.Index(0)
-		if file.Truthy() {
-			SetButtonDisabled(fileInputQuery, true)
-			SetButtonDisabled(submitButtonQuery, true)
-			reader.Call("addEventListener", "load", readEventsJsFunc)
-			reader.Call("addEventListener", "abort", readEventsJsFunc)
-			reader.Call("addEventListener", "error", readEventsJsFunc)
-			reader.Call("readAsText", file)
-		}
-	})
-	fileInput.Call("addEventListener", "change", inputChangeJsFunc)
-	jsFuncs[fileInputQuery+"_readEvents"] = readEventsJsFunc
-	jsFuncs[fileInputQuery+"_inputChange"] = inputChangeJsFunc
-}
-
 func initOtp(ctx context.Context, wg *sync.WaitGroup) {
-	jsFuncs := make(map[string]js.Func, 6)
-	addFileReader(jsFuncs, &encryptKeyReader, &encryptKey, "#encrypt-key", "#encrypt-submit")
-	addFileReader(jsFuncs, &decryptKeyReader, &decryptKey, "#decrypt-key", "#decrypt-submit")
-	addFileReader(jsFuncs, &decryptCipherReader, &decryptCipherText, "#decrypt-cipher", "#decrypt-submit")
+	jsFuncs := make(map[string]js.Func, 16)
+	addPemInput(jsFuncs, "#encrypt-key", "#encrypt-submit", false, true, readEncryptKey)
+	addPemInput(jsFuncs, "#decrypt-key", "#decrypt-submit", false, true, readDecryptKey)
+	addPemInput(jsFuncs, "#decrypt-cipher", "#decrypt-submit", false, false, readDecryptCipher)
+	addCapacityListeners(jsFuncs)
+	readLinkMailbox()
 	wg.Add(1)
 	go ReleaseJsFuncsOnDone(ctx, wg, jsFuncs)
 }
 
+// readEncryptKey is executed when a key that is not from the vault is read to encrypt messages.
+func readEncryptKey(text []byte) {
+	if err := setKey(&encryptKey, text); err != nil {
+		logError(describeFailure("could not read key", err))
+	}
+	vaultMu.Lock()
+	encryptVaultID = ""
+	vaultMu.Unlock()
+	updateCapacity()
+}
+
+// readDecryptKey is executed when a key that is not from the vault is read to decrypt messages.
+func readDecryptKey(text []byte) {
+	if err := setKey(&decryptKey, text); err != nil {
+		logError(describeFailure("could not read key", err))
+	}
+	vaultMu.Lock()
+	decryptVaultID = ""
+	vaultMu.Unlock()
+}
+
+// readDecryptCipher is executed when cipher text is read to decrypt.
+func readDecryptCipher(text []byte) {
+	decryptCipherText = string(text)
+}
+
+// addCapacityListeners registers functions to update the capacity when the message or options to encrypt it change.
+func addCapacityListeners(jsFuncs map[string]js.Func) {
+	updateCapacityJsFunc := NewJsFunc(updateCapacity)
+	for _, query := range []string{"#encrypt-message", "#encrypt-sender", "#encrypt-offset", "#encrypt-compress"} {
+		element := QuerySelector(query)
+		element.Call("addEventListener", "input", updateCapacityJsFunc)
+	}
+	jsFuncs["#encrypt-capacity_update"] = updateCapacityJsFunc
+}
+
 // encryptMessage is executed when the user encrypts a message using a key.
+// The offset is advanced past the part of the key that was used so it is not used again.
+// If the key is from the vault, the cipher is only output after the vault saves the used part of the key.
 func encryptMessage(event js.Value) {
+	if encryptKey == nil {
+		logError("could not encrypt message: choose a key first")
+		return
+	}
 	message := Value("#encrypt-message")
-	cipher, err := otp.Encrypt(message, encryptKey)
+	opts, err := encryptOptions()
 	if err != nil {
+		logError(describeFailure("could not encrypt message", err))
+		return
+	}
+	if err := checkVaultOptions(opts); err != nil {
 		logError("could not encrypt message: " + err.Error())
 		return
 	}
-	savePem("cipher", cipher)
+	key := encryptKey // the key can be replaced while the vault is saved
+	cipher, err := encrypt(opts, message, key)
+	if err != nil {
+		logError(describeFailure("could not encrypt message", err))
+		return
+	}
+	n, err := padLength(opts, message)
+	if err != nil {
+		logError("could not determine used part of key: " + err.Error())
+		return
+	}
+	offset := opts.Offset + n
+	SetValue("#encrypt-offset", strconv.Itoa(offset))
+	updateCapacity()
+	if err := consumeVaultKey(opts.Sender, offset); err != nil {
+		logError("could not encrypt message: " + err.Error())
+		return
+	}
+	recordMessage(true, message, key, string(cipher))
+	output := Value("#encrypt-output")
+	outputCipher(cipher, output)
 }
 
-// decryptCipher is executed when the user decrypts a cipher using a key.
-func decryptCipher(event js.Value) {
-	message, err := otp.Decrypt(decryptCipherText, decryptKey)
+// encryptOptions creates options to encrypt with from the form.
+// The sender's region is used when the sender is specified for a group key.
+func encryptOptions() (otp.Options, error) {
+	opts := otp.Options{
+		Sender:   strings.TrimSpace(Value("#encrypt-sender")),
+		Compress: Checked("#encrypt-compress"),
+	}
+	offset, err := strconv.Atoi(Value("#encrypt-offset"))
 	if err != nil {
-		logError("could not decrypt cipher: " + err.Error())
-		return
+		return opts, errors.New("converting offset to number: " + err.Error())
 	}
-	trimmedMessage := strings.TrimRight(string(message), "\x00")
-	SetValue("#decrypted-message", trimmedMessage)
-	SetChecked(".has-decrypted-message", true)
+	opts.Offset = offset
+	return opts, nil
 }
 
-// generateKey is executed when the user creates a new key.
-func generateKey(event js.Value) {
-	keySizeText := Value("#key-size")
-	keySize, err := strconv.Atoi(keySizeText)
+// updateCapacity shows how many bytes of the key the message will use when it is encrypted.
+// Encrypting is disabled when the message will not fit in the unused part of the key.
+func updateCapacity() {
+	message := Value("#encrypt-message")
+	text, ok := capacity(message, encryptKey)
+	element := QuerySelector("#encrypt-capacity")
+	element.Set("textContent", text)
+	element.Get("classList").Call("toggle", "error", !ok)
+	SetButtonDisabled("#encrypt-submit", !ok)
+}
+
+// capacity describes how much of the key the message uses and whether or not the message fits in the key.
+func capacity(message string, key *otp.Key) (string, bool) {
+	opts, err := encryptOptions()
 	if err != nil {
-		logError("could not convert key size to number: " + err.Error())
+		return err.Error(), false
+	}
+	if err := checkVaultOptions(opts); err != nil {
+		return err.Error(), false
+	}
+	n, err := padLength(opts, message)
+	if err != nil {
+		return err.Error(), false
+	}
+	used := "uses " + strconv.Itoa(n) + " key bytes"
+	if key == nil {
+		return used, true
+	}
+	remaining, err := key.Capacity(opts)
+	switch {
+	case err != nil:
+		return describeFailure("cannot use key", err), false
+	case n > remaining:
+		return "message uses " + strconv.Itoa(n) + " key bytes, but only " + strconv.Itoa(remaining) + " remain: " +
+			"shorten the message by " + strconv.Itoa(n-remaining) + " bytes, compress it, or use a larger key", false
+	}
+	return used + " of " + strconv.Itoa(remaining) + " remaining", true
+}
+
+// decryptCipher is executed when the user decrypts a cipher using a key.
+// Attachments in the message are listed so they can be downloaded.
+// If the key is from the vault, the message is only shown after the vault saves the part of the key the cipher used.
+func decryptCipher(event js.Value) {
+	if decryptKey == nil {
+		logError("could not decrypt cipher: choose a key first")
 		return
 	}
-	key, err := otp.GenerateKey(keySize)
+	key := decryptKey // the key can be replaced while the vault is saved
+	c, err := key.DecryptContainer([]byte(decryptCipherText))
 	if err != nil {
-		logError("could not create key file: " + err.Error())
+		logError(describeFailure("could not decrypt cipher", err))
+		return
+	}
+	trimmedMessage := strings.TrimRight(c.Body, "\x00")
+	cipher := decryptCipherText
+	if err := consumeVaultCipher(cipher); err != nil {
+		logError("could not decrypt cipher: " + err.Error())
 		return
 	}
-	savePem("key", key)
+	recordMessage(false, trimmedMessage, key, cipher)
+	SetValue("#decrypted-message", trimmedMessage)
+	showDecryptedAttachments(c.Attachments)
+	SetChecked(".has-decrypted-message", true)
 }
 
 // savePem creates a new timestamped pem file and downloads it through the user's browser.
 func savePem(name string, data []byte) {
-	time := FormatTime(time.Now().Unix())
-	time = strings.ReplaceAll(time, ":", "_")
-	fileName := name + "_" + time + ".pem"
+	fileName := pemFileName(name)
+	saveFile(fileName, "text/plain", jsBytes(data))
+}
+
+// saveFile downloads the data as a file of the type through the user's browser.
+// The data is a string or a Uint8Array.
+func saveFile(fileName, fileType string, data interface{}) {
 	global := js.Global()
 	blob := global.Get("Blob")
 	dataArr := []interface{}{
-		string(data),
+		data,
 	}
-	fileType := map[string]interface{}{
-		"type": "text/plain",
+	blobOptions := map[string]interface{}{
+		"type": fileType,
 	}
-	fileBlob := blob.New(dataArr, fileType)
+	fileBlob := blob.New(dataArr, blobOptions)
 	url := global.Get("URL")
 	fileURL := url.Call("createObjectURL", fileBlob)
 	defer url.Call("revokeObjectURL", fileURL)
@@ -135,3 +217,10 @@ func savePem(name string, data []byte) {
 	a.Call("click")
 	logInfo("downloaded " + fileName)
 }
+
+// pemFileName creates a timestamped name for a pem file.
+func pemFileName(name string) string {
+	time := FormatTime(time.Now().Unix())
+	time = strings.ReplaceAll(time, ":", "_")
+	return name + "_" + time + ".pem"
+}
@@ -0,0 +1,97 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"syscall/js"
+)
+
+// lastCipher is the most recently encrypted message, which can always be downloaded.
+var lastCipher []byte
+
+// outputCipher outputs the cipher using the method: download, copy, share, show, or mailbox.
+// The cipher can be downloaded later if another method is used.
+func outputCipher(cipher []byte, method string) {
+	lastCipher = cipher
+	SetChecked(".has-cipher-text", false)
+	switch method {
+	case "copy":
+		copyPem(cipher)
+	case "share":
+		sharePem("cipher", cipher)
+	case "show":
+		showCipher(cipher)
+	case "mailbox":
+		go postMailbox(cipher)
+	default:
+		savePem("cipher", cipher)
+	}
+}
+
+// downloadCipher is executed when the user downloads the last cipher.
+func downloadCipher(event js.Value) {
+	if len(lastCipher) == 0 {
+		logError("no encrypted message to download")
+		return
+	}
+	savePem("cipher", lastCipher)
+}
+
+// copyPem writes the pem text to the clipboard.
+func copyPem(data []byte) {
+	global := js.Global()
+	clipboard := global.Get("navigator").Get("clipboard")
+	if !clipboard.Truthy() {
+		logError("clipboard not available, showing text instead")
+		showCipher(data)
+		return
+	}
+	promise := clipboard.Call("writeText", string(data))
+	OnSettled(promise, func(err error) {
+		if err != nil {
+			logError("could not copy to clipboard, showing text instead: " + err.Error())
+			showCipher(data)
+			return
+		}
+		logInfo("copied to clipboard")
+		showDownload()
+	})
+}
+
+// sharePem shares the pem text through the browser's share dialog.
+func sharePem(name string, data []byte) {
+	global := js.Global()
+	navigator := global.Get("navigator")
+	if !navigator.Get("share").Truthy() {
+		logError("sharing not available, showing text instead")
+		showCipher(data)
+		return
+	}
+	shareData := map[string]interface{}{
+		"title": pemFileName(name),
+		"text":  string(data),
+	}
+	promise := navigator.Call("share", shareData)
+	OnSettled(promise, func(err error) {
+		if err != nil {
+			logError("could not share, showing text instead: " + err.Error())
+			showCipher(data)
+			return
+		}
+		logInfo("shared " + name)
+		showDownload()
+	})
+}
+
+// showCipher shows the cipher text in a read-only text area.
+func showCipher(cipher []byte) {
+	SetValue("#cipher-text", string(cipher))
+	SetChecked(".has-cipher-text", true)
+	logInfo("showing encrypted message text")
+}
+
+// showDownload shows the download button for the last cipher without showing its text.
+func showDownload() {
+	SetValue("#cipher-text", "")
+	SetChecked(".has-cipher-text", true)
+}
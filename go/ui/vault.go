@@ -0,0 +1,687 @@
+//go:build js && wasm
+
+package ui
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/jacobpatterson1549/sarah-otp/go/otp"
+)
+
+// vaultMetaID is the id of the record in the meta store with the salt for the vault passphrase.
+const vaultMetaID = "vault"
+
+// vaultCheck is sealed when the vault is created to check the passphrase when the vault is unlocked.
+const vaultCheck = "sarah-otp vault"
+
+// vaultEntry is a key in the vault.
+// Entries are sealed with the vault lock when they are stored.
+type vaultEntry struct {
+	ID          string `json:"-"`
+	Label       string `json:"label"`
+	Partner     string `json:"partner"`
+	Fingerprint string `json:"fingerprint"`
+	// Key is encoded as the text of a key file when the entry is sealed.
+	// It is shared by copies of the entry and destroyed when the vault is locked or the entry is destroyed.
+	Key *otp.Key `json:"key"`
+	// Sender is the user's name in a group key, whose region the offset is in.
+	// It is empty for keys shared by two users and for group keys before they are first used.
+	Sender string `json:"sender,omitempty"`
+	// Offset is the index of the first byte of the key, or of the sender's region, that has not been used by a message.
+	// Messages from the partner that use the same bytes advance the offset too, so the bytes are not used again.
+	Offset  int   `json:"offset"`
+	Created int64 `json:"created"`
+}
+
+var (
+	// vaultKey is the text of the keys to add to the vault.  It is cleared after the keys are added.
+	vaultKey []byte
+	// vaultLock seals and opens vault entries.  It is nil when the vault is locked.
+	vaultLock *otp.Lock
+	// vaultEntries are the unlocked vault entries, by id.
+	vaultEntries map[string]vaultEntry
+	// encryptVaultID is the id of the vault entry whose key is used to encrypt messages, if any.
+	encryptVaultID string
+	// decryptVaultID is the id of the vault entry whose key is used to decrypt messages, if any.
+	decryptVaultID string
+	// vaultMu guards the vault variables, which are changed on separate goroutines.
+	vaultMu sync.Mutex
+)
+
+// initVault registers the vault javascript functions.
+func initVault(ctx context.Context, wg *sync.WaitGroup) {
+	vaultFuncs := map[string]js.Func{
+		"unlock": NewJsAsyncEventFunc(unlockVault),
+		"lock":   NewJsFunc(lockVault),
+		"add":    NewJsAsyncEventFunc(addVaultKey),
+		"export": NewJsFunc(exportVault),
+	}
+	RegisterFuncs(ctx, wg, "vault", vaultFuncs)
+	jsFuncs := make(map[string]js.Func, 7)
+	addPemInput(jsFuncs, "#vault-key", "#vault-submit", true, true, readVaultKey)
+	vaultKeysClickJsFunc := NewJsAsyncEventFunc(handleVaultKeysClick)
+	vaultKeys := QuerySelector(".vault-keys>tbody")
+	vaultKeys.Call("addEventListener", "click", vaultKeysClickJsFunc)
+	jsFuncs[".vault-keys_click"] = vaultKeysClickJsFunc
+	wg.Add(1)
+	go ReleaseJsFuncsOnDone(ctx, wg, jsFuncs)
+}
+
+// unlockVault is executed when the user unlocks the vault with a passphrase.
+// The vault is created the first time it is unlocked.
+func unlockVault(event js.Value) {
+	passphrase := Value("#vault-passphrase")
+	SetValue("#vault-passphrase", "")
+	logInfo("unlocking vault...")
+	l, err := vaultLockFor(passphrase)
+	if err != nil {
+		logError(describeFailure("could not unlock vault", err))
+		return
+	}
+	entries, err := loadVaultEntries(*l)
+	if err != nil {
+		logError("could not load vault: " + err.Error())
+		return
+	}
+	messages, err := loadHistory(*l)
+	if err != nil {
+		logError("could not load history: " + err.Error())
+		return
+	}
+	vaultMu.Lock()
+	vaultLock = l
+	vaultEntries = entries
+	historyEntries = messages
+	vaultMu.Unlock()
+	renderVault()
+	renderHistory()
+	SetChecked(".vault-unlocked", true)
+	logInfo("vault unlocked")
+}
+
+// vaultLockFor creates the lock for the passphrase, checking it against the stored vault metadata.
+func vaultLockFor(passphrase string) (*otp.Lock, error) {
+	meta, err := idbGet(metaStore, vaultMetaID)
+	if err != nil {
+		return nil, err
+	}
+	if !meta.Truthy() {
+		return createVault(passphrase)
+	}
+	salt := goBytes(meta.Get("salt"))
+	iterations := meta.Get("iterations").Int()
+	l, err := otp.NewLock(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	check := goBytes(meta.Get("check"))
+	if _, err := l.Open(check); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// createVault stores the metadata for a new vault that is unlocked by the passphrase.
+func createVault(passphrase string) (*otp.Lock, error) {
+	salt, err := otp.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	l, err := otp.NewLock(passphrase, salt, otp.PassphraseIterations)
+	if err != nil {
+		return nil, err
+	}
+	check, err := l.Seal([]byte(vaultCheck))
+	if err != nil {
+		return nil, err
+	}
+	meta := map[string]interface{}{
+		"id":         vaultMetaID,
+		"salt":       jsBytes(salt),
+		"iterations": otp.PassphraseIterations,
+		"check":      jsBytes(check),
+	}
+	if err := idbPut(metaStore, meta); err != nil {
+		return nil, err
+	}
+	logInfo("created vault")
+	return l, nil
+}
+
+// loadVaultEntries opens all of the sealed entries in the vault.
+func loadVaultEntries(l otp.Lock) (map[string]vaultEntry, error) {
+	entries := make(map[string]vaultEntry)
+	err := getAllSealed(l, keysStore, func(id string, data []byte) error {
+		var e vaultEntry
+		err := json.Unmarshal(data, &e)
+		switch {
+		case err != nil:
+			if e.Key != nil {
+				e.Key.Destroy()
+			}
+			return err
+		case e.Key == nil:
+			return errors.New("no key")
+		}
+		e.ID = id
+		entries[id] = e
+		return nil
+	})
+	if err != nil {
+		destroyVaultEntries(entries)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// destroyVaultEntries destroys the keys of the entries.
+func destroyVaultEntries(entries map[string]vaultEntry) {
+	for _, e := range entries {
+		e.Key.Destroy()
+	}
+}
+
+// getAllSealed opens all of the sealed records in the object store with the lock.
+// The function is called with the id and opened data of each record, which is cleared after the function returns.
+func getAllSealed(l otp.Lock, storeName string, fn func(id string, data []byte) error) error {
+	records, err := idbGetAll(storeName)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		id := r.Get("id").String()
+		data, err := l.Open(goBytes(r.Get("sealed")))
+		if err != nil {
+			return errors.New("opening " + id + ": " + err.Error())
+		}
+		err = fn(id, data)
+		clear(data)
+		if err != nil {
+			return errors.New("reading " + id + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// putSealed seals the value as json with the vault lock and stores it in the object store with the id.
+// The json is cleared after it is sealed because it can have keys.
+func putSealed(storeName, id string, v interface{}) error {
+	vaultMu.Lock()
+	l := vaultLock
+	vaultMu.Unlock()
+	if l == nil {
+		return errors.New("vault locked")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.New("writing " + id + ": " + err.Error())
+	}
+	defer clear(data)
+	sealed, err := l.Seal(data)
+	if err != nil {
+		return err
+	}
+	record := map[string]interface{}{
+		"id":     id,
+		"sealed": jsBytes(sealed),
+	}
+	return idbPut(storeName, record)
+}
+
+// lockVault is executed when the user locks the vault.
+// Keys from the vault are destroyed.
+func lockVault() {
+	vaultMu.Lock()
+	vaultLock = nil
+	destroyVaultEntries(vaultEntries)
+	vaultEntries = nil
+	historyEntries = nil
+	usingVaultKey := len(encryptVaultID) != 0
+	encryptVaultID = ""
+	decryptVaultID = ""
+	vaultMu.Unlock()
+	if usingVaultKey {
+		destroyKey(&encryptKey)
+		SetText("#encrypt-key-status", "")
+		updateCapacity()
+	}
+	renderVault()
+	renderHistory()
+	SetChecked(".vault-unlocked", false)
+	logInfo("vault locked")
+}
+
+// readVaultKey is executed when the text of keys to add to the vault is read.
+// The text is copied because it is cleared after it is read.
+func readVaultKey(text []byte) {
+	clear(vaultKey)
+	vaultKey = bytes.Clone(text)
+}
+
+// addVaultKey is executed when the user adds a key, a bundle of keys, or a keyring to the vault.
+// The used parts of keys from keyrings are not used again.
+func addVaultKey(event js.Value) {
+	var k otp.Keyring
+	if err := k.UnmarshalText(vaultKey); err != nil {
+		logError(describeFailure("could not add key to vault", err))
+		return
+	}
+	defer k.Destroy()
+	for _, e := range k.Entries {
+		text, err := e.Key()
+		if err != nil {
+			logError(describeFailure("could not add key to vault", err))
+			return
+		}
+		key, err := otp.ParseKey(text)
+		clear(text)
+		if err != nil {
+			logError(describeFailure("could not add key to vault", err))
+			return
+		}
+		label := cmp.Or(strings.TrimSpace(Value("#vault-label")), e.Label)
+		partner := cmp.Or(strings.TrimSpace(Value("#vault-partner")), e.Partner)
+		sender := strings.TrimSpace(Value("#vault-sender"))
+		if err := addToVault(label, partner, sender, key, e.Next()); err != nil {
+			logError(describeFailure("could not add key to vault", err))
+			return
+		}
+	}
+	clear(vaultKey)
+	vaultKey = nil
+	SetValue("#vault-label", "")
+	SetValue("#vault-partner", "")
+	SetValue("#vault-sender", "")
+	SetValue("#vault-key", "")
+	SetValue("#vault-key-text", "")
+	SetText("#vault-key-status", "")
+}
+
+// addToVault saves the key in the vault with the label and partner.
+// The sender is the user's name in a group key, if known, and the offset is the index of the first unused byte of the key or the sender's region.
+// The fingerprint of the key is used as the label if the label is empty.
+// The vault keeps the key, which is destroyed if it cannot be added.
+// This must be called on a separate goroutine because it waits for javascript callbacks.
+func addToVault(label, partner, sender string, key *otp.Key, offset int) error {
+	if _, err := key.Capacity(otp.Options{Sender: sender, Offset: offset}); err != nil {
+		key.Destroy()
+		return err
+	}
+	id, err := newID()
+	if err != nil {
+		key.Destroy()
+		return err
+	}
+	fingerprint := key.Fingerprint()
+	e := vaultEntry{
+		ID:          id,
+		Label:       cmp.Or(label, fingerprint),
+		Partner:     partner,
+		Fingerprint: fingerprint,
+		Key:         key,
+		Sender:      sender,
+		Offset:      offset,
+		Created:     time.Now().Unix(),
+	}
+	if err := saveVaultEntry(e); err != nil {
+		key.Destroy()
+		return err
+	}
+	logInfo("added " + e.Label + " to vault")
+	return nil
+}
+
+// exportVault downloads the keys in the vault as a keyring.
+// The used part of each key is recorded in the keyring so it is not used again after it is imported.
+func exportVault() {
+	vaultMu.Lock()
+	entries := make([]vaultEntry, 0, len(vaultEntries))
+	for _, e := range vaultEntries {
+		entries = append(entries, e)
+	}
+	vaultMu.Unlock()
+	slices.SortFunc(entries, func(a, b vaultEntry) int {
+		return cmp.Compare(a.Created, b.Created)
+	})
+	var k otp.Keyring
+	defer k.Destroy()
+	for _, e := range entries {
+		id, err := k.AddKey(e.Key, time.Unix(e.Created, 0))
+		if err != nil {
+			logError(describeNamedFailure("could not export {name}", e.Label, err))
+			return
+		}
+		if e.Offset > 0 {
+			if err := k.Consume(id, 0, e.Offset); err != nil {
+				logError(describeNamedFailure("could not export {name}", e.Label, err))
+				return
+			}
+		}
+		i := len(k.Entries) - 1
+		k.Entries[i].Label = e.Label
+		k.Entries[i].Partner = e.Partner
+	}
+	b, err := k.MarshalText()
+	if err != nil {
+		logError(describeFailure("could not export vault", err))
+		return
+	}
+	defer clear(b)
+	saveFile(pemFileName("keyring"), "text/plain", jsBytes(b))
+}
+
+// saveVaultEntry seals and stores the entry, then shows the updated vault.
+// The used part of the key is kept if the entry is older than the unlocked vault, such as when it is renamed.
+func saveVaultEntry(e vaultEntry) error {
+	vaultMu.Lock()
+	if current, ok := vaultEntries[e.ID]; ok && current.Offset > e.Offset {
+		e.Sender, e.Offset = current.Sender, current.Offset
+	}
+	vaultMu.Unlock()
+	if err := putSealed(keysStore, e.ID, e); err != nil {
+		return err
+	}
+	vaultMu.Lock()
+	if vaultEntries != nil {
+		vaultEntries[e.ID] = e
+	}
+	vaultMu.Unlock()
+	renderVault()
+	return nil
+}
+
+// newID creates a random id for a record.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.New("generating id: " + err.Error())
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// renderVault shows the entries of the vault, sorted by label.
+func renderVault() {
+	vaultMu.Lock()
+	entries := make([]vaultEntry, 0, len(vaultEntries))
+	for _, e := range vaultEntries {
+		entries = append(entries, e)
+	}
+	vaultMu.Unlock()
+	slices.SortFunc(entries, func(a, b vaultEntry) int {
+		return strings.Compare(a.Label, b.Label)
+	})
+	vaultKeys := QuerySelector(".vault-keys>tbody")
+	vaultKeys.Set("innerHTML", "")
+	for _, e := range entries {
+		clone := CloneElement(".vault-key")
+		row := clone.Get("children").Index(0)
+		row.Get("dataset").Set("id", e.ID)
+		remaining := "?"
+		if n, err := e.Key.Capacity(otp.Options{Sender: e.Sender, Offset: e.Offset}); err == nil {
+			remaining = strconv.Itoa(n)
+		}
+		for query, text := range map[string]string{
+			".label":       e.Label,
+			".partner":     e.Partner,
+			".fingerprint": e.Fingerprint,
+			".remaining":   remaining,
+		} {
+			cell := row.Call("querySelector", query)
+			cell.Set("textContent", text)
+		}
+		vaultKeys.Call("appendChild", row)
+	}
+}
+
+// handleVaultKeysClick is executed when the user clicks a button of a key in the vault.
+func handleVaultKeysClick(event js.Value) {
+	target := event.Get("target")
+	button := target.Call("closest", "button")
+	if !button.Truthy() {
+		return
+	}
+	row := button.Call("closest", "tr")
+	id := row.Get("dataset").Get("id").String()
+	vaultMu.Lock()
+	e, ok := vaultEntries[id]
+	vaultMu.Unlock()
+	if !ok {
+		logError("key not in vault: " + id)
+		return
+	}
+	classList := button.Get("classList")
+	switch {
+	case classList.Call("contains", "encrypt").Bool():
+		useVaultKeyToEncrypt(e)
+	case classList.Call("contains", "decrypt").Bool():
+		useVaultKeyToDecrypt(e)
+	case classList.Call("contains", "rename").Bool():
+		renameVaultKey(e)
+	case classList.Call("contains", "destroy").Bool():
+		destroyVaultKey(e)
+	}
+}
+
+// useVaultKeyToEncrypt uses the key to encrypt messages, starting at its first unused byte.
+func useVaultKeyToEncrypt(e vaultEntry) {
+	vaultMu.Lock()
+	encryptVaultID = e.ID
+	vaultMu.Unlock()
+	if err := setVaultKey(&encryptKey, e); err != nil {
+		logError(describeFailure("could not use vault key", err))
+		return
+	}
+	SetValue("#encrypt-key", "")
+	SetValue("#encrypt-key-text", "")
+	SetText("#encrypt-key-status", "using vault key: "+e.Label)
+	SetValue("#encrypt-sender", e.Sender)
+	SetValue("#encrypt-offset", strconv.Itoa(e.Offset))
+	SetChecked("#tab-encrypt", true)
+	updateCapacity()
+}
+
+// useVaultKeyToDecrypt uses the key to decrypt ciphers.
+// The parts of the key that decrypted ciphers are recorded as used.
+func useVaultKeyToDecrypt(e vaultEntry) {
+	vaultMu.Lock()
+	decryptVaultID = e.ID
+	vaultMu.Unlock()
+	if err := setVaultKey(&decryptKey, e); err != nil {
+		logError(describeFailure("could not use vault key", err))
+		return
+	}
+	SetValue("#decrypt-key", "")
+	SetValue("#decrypt-key-text", "")
+	SetText("#decrypt-key-status", "using vault key: "+e.Label)
+	SetChecked("#tab-decrypt", true)
+}
+
+// setVaultKey replaces the key with a copy of the key of the entry, so the vault keeps its key when the copy is destroyed.
+func setVaultKey(key **otp.Key, e vaultEntry) error {
+	text, err := e.Key.MarshalText()
+	if err != nil {
+		return err
+	}
+	defer clear(text)
+	return setKey(key, text)
+}
+
+// renameVaultKey prompts the user for a new label for the key.
+func renameVaultKey(e vaultEntry) {
+	global := js.Global()
+	label := global.Call("prompt", "New label for "+e.Label+":", e.Label)
+	if !label.Truthy() {
+		return
+	}
+	oldLabel := e.Label
+	e.Label = strings.TrimSpace(label.String())
+	if err := saveVaultEntry(e); err != nil {
+		logError("could not rename key: " + err.Error())
+		return
+	}
+	logInfo("renamed " + oldLabel + " to " + e.Label)
+}
+
+// destroyVaultKey removes the key from the vault after the user confirms it.
+func destroyVaultKey(e vaultEntry) {
+	global := js.Global()
+	message := "Destroy " + e.Label + " (" + e.Fingerprint + ")?  It cannot be recovered."
+	if !global.Call("confirm", message).Bool() {
+		return
+	}
+	if err := idbDelete(keysStore, e.ID); err != nil {
+		logError("could not destroy key: " + err.Error())
+		return
+	}
+	vaultMu.Lock()
+	delete(vaultEntries, e.ID)
+	e.Key.Destroy()
+	usingVaultKey := encryptVaultID == e.ID
+	if usingVaultKey {
+		encryptVaultID = ""
+	}
+	if decryptVaultID == e.ID {
+		decryptVaultID = ""
+	}
+	vaultMu.Unlock()
+	if usingVaultKey {
+		destroyKey(&encryptKey)
+		SetText("#encrypt-key-status", "")
+		updateCapacity()
+	}
+	renderVault()
+	logInfo("destroyed " + e.Label)
+}
+
+// checkVaultOptions checks that encrypting with the options does not reuse the part of the key that the vault records as used, if the key is from the vault.
+// The sender of a group key is recorded when the key is first used.
+func checkVaultOptions(opts otp.Options) error {
+	vaultMu.Lock()
+	e, ok := vaultEntries[encryptVaultID]
+	vaultMu.Unlock()
+	switch {
+	case !ok:
+		return nil
+	case !e.recordsSender(opts.Sender):
+		return errors.New("the vault records the used part of the key for sender \"" + e.Sender + "\": use that sender")
+	case opts.Offset < e.Offset:
+		return errors.New("the vault key is used before byte " + strconv.Itoa(e.Offset) + ": use an offset of at least " + strconv.Itoa(e.Offset))
+	}
+	return nil
+}
+
+// consumeVaultKey records and saves that the key used to encrypt messages is used by the sender up to the offset, if it is from the vault.
+// It waits for the vault to be saved, so it must not be called on the javascript event loop.
+func consumeVaultKey(sender string, offset int) error {
+	vaultMu.Lock()
+	id := encryptVaultID
+	vaultMu.Unlock()
+	e, ok := recordVaultEntry(id, sender, offset)
+	if !ok {
+		return nil
+	}
+	if err := saveVaultEntry(e); err != nil {
+		return errors.New("recording used part of vault key: " + err.Error())
+	}
+	return nil
+}
+
+// consumeVaultCipher records and saves the part of the key that encrypted the cipher text as used, if the key to decrypt messages is from the vault.
+// Only ciphers from the region of the user's sender use bytes that the user could encrypt with.
+// It waits for the vault to be saved, so it must not be called on the javascript event loop.
+func consumeVaultCipher(cipher string) error {
+	vaultMu.Lock()
+	e, ok := vaultEntries[decryptVaultID]
+	vaultMu.Unlock()
+	if !ok {
+		return nil
+	}
+	opts, err := otp.ParseOptions(cipher)
+	if err != nil {
+		return errors.New("recording used part of vault key: " + err.Error())
+	}
+	if opts.Sender != e.Sender {
+		return nil
+	}
+	r, err := otp.CipherRange(cipher)
+	if err != nil {
+		return errors.New("recording used part of vault key: " + err.Error())
+	}
+	e, ok = recordVaultEntry(e.ID, e.Sender, r.End)
+	if !ok {
+		return nil
+	}
+	if err := saveVaultEntry(e); err != nil {
+		return errors.New("recording used part of vault key: " + err.Error())
+	}
+	return nil
+}
+
+// consumeVaultEntry records that the key of the vault entry is used by the sender up to the offset.
+// The vault is updated on a separate goroutine because it waits for javascript callbacks.
+func consumeVaultEntry(id, sender string, offset int) {
+	e, ok := recordVaultEntry(id, sender, offset)
+	if !ok {
+		return
+	}
+	go func() {
+		defer AlertOnPanic()
+		if err := saveVaultEntry(e); err != nil {
+			logError("could not record used part of vault key: " + err.Error())
+		}
+	}()
+}
+
+// recordVaultEntry records that the key of the unlocked vault entry is used by the sender up to the offset, returning the entry to save.
+// The offset never moves backward, so bytes that were used are not used again.
+// The offset to encrypt with is advanced too if the key is used to encrypt messages.
+func recordVaultEntry(id, sender string, offset int) (vaultEntry, bool) {
+	vaultMu.Lock()
+	e, ok := vaultEntries[id]
+	if ok {
+		e.Sender = sender
+		e.Offset = max(e.Offset, offset)
+		vaultEntries[id] = e
+	}
+	encrypting := ok && id == encryptVaultID
+	vaultMu.Unlock()
+	if !ok {
+		return vaultEntry{}, false
+	}
+	if current, err := strconv.Atoi(Value("#encrypt-offset")); encrypting && (err != nil || current < e.Offset) {
+		SetValue("#encrypt-offset", strconv.Itoa(e.Offset))
+		updateCapacity()
+	}
+	return e, true
+}
+
+// findVaultEntry finds the unlocked vault entry of the key that records the used part of the key for the sender.
+func findVaultEntry(key *otp.Key, sender string) (vaultEntry, bool) {
+	fingerprint := key.Fingerprint()
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	for _, e := range vaultEntries {
+		if e.Fingerprint == fingerprint && e.recordsSender(sender) {
+			return e, true
+		}
+	}
+	return vaultEntry{}, false
+}
+
+// recordsSender reports whether or not the offset of the entry is in the region of the sender.
+// Entries of keys that have not been used yet record any sender.
+func (e vaultEntry) recordsSender(sender string) bool {
+	return e.Sender == sender || (len(e.Sender) == 0 && e.Offset == 0)
+}
@@ -1,12 +1,36 @@
 window.addEventListener("load", () => {
     if ("serviceWorker" in navigator) {
-        navigator.serviceWorker.register("./serviceWorker.js");
+        navigator.serviceWorker.register("/serviceWorker.js");
     }
     const go = new Go();
+    // The fetch fails if the WebAssembly does not match the integrity digest of the server, so it is never instantiated.
     WebAssembly.instantiateStreaming(
-            fetch("/main.wasm"),
+            fetch("/main.wasm?v={{.Version}}", { integrity: {{.MainWasmIntegrity}} }),
             go.importObject)
         .then(async (result) => {
             await go.run(result.instance);
+        })
+        .catch((error) => {
+            alert("could not load WebAssembly: " + error);
         });
 });
+
+// Elements name the functions that handle their events in data-on* attributes, such as data-onclick="log.clear".
+// Inline event handler attributes are not used because the content security policy blocks them.
+for (const type of ["click", "submit", "change"]) {
+    document.addEventListener(type, (event) => {
+        const element = event.target.closest("[data-on" + type + "]");
+        if (element == null) {
+            return;
+        }
+        const name = element.getAttribute("data-on" + type);
+        const fn = name.split(".").reduce((parent, key) => parent?.[key], window);
+        if (typeof fn !== "function") {
+            if (type === "submit") {
+                event.preventDefault(); // the page is still loading
+            }
+            return;
+        }
+        fn(event);
+    });
+}
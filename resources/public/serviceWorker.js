@@ -1,9 +1,9 @@
 const cacheName = "cache-{{.Version}}";
 const assets = [
-    "./favicon.svg",
-    "./manifest.json",
-    "./wasm_exec.js",
-    "./main.wasm",
+    "./favicon.svg?v={{.Version}}",
+    "./manifest.json?v={{.Version}}",
+    "./wasm_exec.js?v={{.Version}}",
+    "./main.wasm?v={{.Version}}",
     "./network_check.html",
 ];
 